@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// checkJobs compares the generated output of every job with the file on
+// disk, printing a unified diff to w for each stale file. It returns the
+// number of stale files.
+func checkJobs(w io.Writer, jobs []job) (int, error) {
+	cache := generator.NewCache()
+	stale := 0
+	for _, j := range jobs {
+		d, err := j.check(cache)
+		if err != nil {
+			return stale, err
+		}
+		if d != "" {
+			stale++
+			fmt.Fprint(w, d)
+		}
+	}
+	return stale, nil
+}
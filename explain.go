@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// runExplain describes what a generator does to its upstream source.
+func runExplain(args []string) error {
+	fs := newFlagSet("explain", flag.ExitOnError)
+	name := fs.String("name", "Example", "name of the generated type used for the explanation")
+	typ := fs.String("type", "", "type argument used for the explanation (default: the generator's argument syntax)")
+	version := fs.String("version", "", "upstream module version, for generators of modules such as singleflight")
+	upstream := fs.String("upstream", generator.Embedded, "read upstream sources from the embedded snapshots (\"embedded\") or the installed toolchain and module cache (\"live\")")
+	goversion := fs.String("goversion", "", "Go release the standard library sources are taken from, such as go1.21.5")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return generator.Errorf(generator.BadArgument, "explain takes a single generator name, see go-generate list")
+	}
+	factory, _ := generator.Lookup(fs.Arg(0))
+	if *typ == "" {
+		*typ = factory.Args
+	}
+
+	r := generator.Request{
+		Options: generator.Options{
+			Name:      *name,
+			Package:   "example",
+			Type:      *typ,
+			Version:   *version,
+			Upstream:  *upstream,
+			GoVersion: *goversion,
+		},
+		Generator: fs.Arg(0),
+	}
+	e, err := r.Explain()
+	if err != nil {
+		return err
+	}
+	printExplanation(os.Stdout, factory, e)
+	return nil
+}
+
+func printExplanation(w io.Writer, f generator.Factory, e *generator.Explanation) {
+	fmt.Fprintf(w, "%s %s: %s\n\n", f.Name, f.Args, f.Description)
+	fmt.Fprintf(w, "source:\n\t%s\n\t%s\n\n", e.Source, e.Source.Path)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "handlers:\n")
+	for _, h := range e.Handlers {
+		at := "missing upstream"
+		if h.Pos.IsValid() {
+			at = fmt.Sprintf("%s:%d", filepath.Base(h.Pos.Filename), h.Pos.Line)
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\n", h.Kind, h.Name, at)
+	}
+	tw.Flush()
+
+	if len(e.Unhandled) > 0 {
+		fmt.Fprintf(tw, "\nunhandled declarations using interface{} or any:\n")
+		for _, h := range e.Unhandled {
+			fmt.Fprintf(tw, "\t%s\t%s\t%s:%d\n", h.Kind, h.Name, filepath.Base(h.Pos.Filename), h.Pos.Line)
+		}
+		tw.Flush()
+	}
+
+	olds := make([]string, 0, len(e.Renames))
+	for old := range e.Renames {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	fmt.Fprintf(tw, "\nrenames:\n")
+	for _, old := range olds {
+		fmt.Fprintf(tw, "\t%s\t=> %s\n", old, e.Renames[old])
+	}
+	tw.Flush()
+}
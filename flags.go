@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joesonw/go-generate/pkg/directive"
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// flags holds the command line of a single go-generate invocation. It is
+// shared by main and by the go:generate directives found by regen.
+type flags struct {
+	out       string
+	name      string
+	pkg       string
+	generator string
+	version   string
+	upstream  string
+	goversion string
+	config    string
+	list      bool
+	check     bool
+	force     bool
+	watch     bool
+	typecheck bool
+	strict    bool
+	tests     bool
+	ported    bool
+	fuzz      bool
+	bench     bool
+	verify    string
+	interval  time.Duration
+	args      []string
+	set       map[string]bool // flags given on the command line.
+}
+
+// parseFlags parses the arguments of the named go-generate command,
+// excluding the command itself.
+func parseFlags(name string, args []string, handling flag.ErrorHandling) (*flags, error) {
+	f := &flags{}
+	fs := newFlagSet(name, handling)
+	if handling == flag.ContinueOnError {
+		fs.SetOutput(ioutil.Discard)
+	}
+	fs.StringVar(&f.out, "out", "", "output file, \"-\" for standard output (default: lower(name)_gen.go)")
+	fs.StringVar(&f.name, "name", "", "name of the generated type, with -generator")
+	fs.StringVar(&f.pkg, "package", "", "package name of the generated file (default: the package of the output directory)")
+	fs.StringVar(&f.generator, "generator", "", "generator to run with -name on a single type argument, see go-generate list")
+	fs.StringVar(&f.version, "version", "", "upstream module version, for generators of modules such as singleflight")
+	fs.StringVar(&f.upstream, "upstream", generator.Embedded, "read upstream sources from the snapshots embedded in go-generate (\"embedded\") or from the installed toolchain and module cache (\"live\")")
+	fs.StringVar(&f.goversion, "goversion", "", "Go release the standard library sources are taken from, such as go1.21.5 (default: the embedded snapshot or the installed toolchain)")
+	fs.BoolVar(&f.list, "list", false, "list the available generators, same as go-generate list")
+	fs.StringVar(&f.config, "config", "", "JSON or YAML manifest of the instantiations to generate")
+	fs.BoolVar(&f.check, "check", false, "report out of date files instead of writing them, same as go-generate check")
+	fs.BoolVar(&f.force, "force", false, "regenerate files even if their recorded inputs are unchanged")
+	fs.BoolVar(&f.watch, "watch", false, "keep running and regenerate when inputs change")
+	fs.BoolVar(&f.typecheck, "typecheck", true, "check the type arguments against the target package")
+	fs.BoolVar(&f.strict, "strict", false, "fail when upstream declarations no longer match the handlers of the generator, or when no upstream test is ported with -upstreamtests")
+	fs.BoolVar(&f.tests, "tests", false, "also generate a companion <name>_gen_test.go exercising the generated API with the type arguments")
+	fs.BoolVar(&f.ported, "upstreamtests", false, "also port the upstream tests to a <name>_gen_upstream_test.go, leaving out those which do not compile with the type arguments; warns if none does, fails with -strict")
+	fs.BoolVar(&f.fuzz, "fuzz", false, "also generate a <name>_gen_fuzz_test.go with fuzz targets comparing the generated types with the originals")
+	fs.BoolVar(&f.bench, "bench", false, "also generate a <name>_gen_bench_test.go benchmarking the generated types against the originals")
+	fs.StringVar(&f.verify, "verify", verifyError, "type check the generated file with its package: \"error\" refuses to write it if it does not compile, \"warn\" writes it and reports the errors, \"off\" skips the check")
+	fs.DurationVar(&f.interval, "interval", time.Second, "polling interval of -watch")
+	if err := fs.Parse(args); err != nil {
+		return nil, &generator.Error{Kind: generator.BadArgument, Msg: "parse flags", Err: err}
+	}
+	f.args = fs.Args()
+	f.set = map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { f.set[fl.Name] = true })
+	return f, nil
+}
+
+// watcher returns a watcher keeping the jobs requested by the flags up
+// to date. It reloads them when the manifest changes or, without one,
+// when the file of the directive at pos changes, reading the directive
+// again.
+func (f *flags) watcher(dir, pkg string, pos token.Position) *watcher {
+	w := &watcher{
+		interval: f.interval,
+		load: func() ([]task, error) {
+			jobs, err := f.jobs(dir, pkg, pos)
+			tasks := make([]task, 0, len(jobs))
+			for _, j := range jobs {
+				tasks = append(tasks, task{Directive: directive.Directive{Pos: pos, Dir: dir, Package: pkg}, job: j})
+			}
+			return tasks, err
+		},
+	}
+	if config := strings.TrimSpace(f.config); config != "" {
+		if !filepath.IsAbs(config) {
+			config = filepath.Join(dir, config)
+		}
+		w.definitions = func() ([]string, error) { return []string{config}, nil }
+	} else if pos.Filename != "" {
+		load := w.load
+		w.load = func() ([]task, error) {
+			directives, err := directive.Scan(pos.Filename)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range directives {
+				if d.Pos.Line == pos.Line {
+					d.Pos = pos
+					return directiveTasks(d)
+				}
+			}
+			return load()
+		}
+		w.definitions = func() ([]string, error) { return []string{pos.Filename}, nil }
+	}
+	return w
+}
+
+// jobs returns the instantiations requested by the flags. Relative paths
+// are resolved against dir. Generated files belong to the package given
+// by -package, to pkg when written to dir, or else to the package found
+// in the directory of the output file. pos is where the flags were given.
+func (f *flags) jobs(dir, pkg string, pos token.Position) ([]job, error) {
+	switch f.verify {
+	case verifyError, verifyWarn, verifyOff:
+	default:
+		return nil, generator.Errorf(generator.BadArgument, "invalid -verify %q, expected %s, %s or %s", f.verify, verifyError, verifyWarn, verifyOff)
+	}
+	if config := strings.TrimSpace(f.config); config != "" {
+		if !filepath.IsAbs(config) {
+			config = filepath.Join(dir, config)
+		}
+		jobs, err := manifestJobs(config)
+		for i := range jobs {
+			jobs[i].Force = f.force
+			jobs[i].Verify = f.verify
+			jobs[i].Tests = f.tests
+			jobs[i].UpstreamTests = f.ported
+			jobs[i].Fuzz = f.fuzz
+			jobs[i].Bench = f.bench
+			// the flags given override the instances.
+			for j := range jobs[i].Requests {
+				r := &jobs[i].Requests[j]
+				if f.set["typecheck"] {
+					r.TypeCheck = f.typecheck
+				}
+				if f.set["strict"] {
+					r.Strict = f.strict
+				}
+				if f.set["upstream"] || r.Upstream == "" {
+					r.Upstream = f.upstream
+				}
+				if f.set["goversion"] {
+					r.GoVersion = f.goversion
+				}
+			}
+		}
+		return jobs, err
+	}
+
+	if len(f.args) == 0 {
+		return nil, generator.Errorf(generator.BadArgument, "missing type expression")
+	}
+	var reqs []generator.Request
+	if program := strings.TrimSpace(f.generator); program != "" {
+		if len(f.args) != 1 {
+			return nil, generator.Errorf(generator.BadArgument, "-generator takes a single type expression, got %d arguments", len(f.args))
+		}
+		reqs = append(reqs, f.request(program, f.name, f.args[0]))
+	} else {
+		// every argument is a generator:name:type instantiation.
+		for _, arg := range f.args {
+			parts := strings.SplitN(arg, ":", 3)
+			if len(parts) != 3 {
+				return nil, generator.Errorf(generator.BadArgument, "invalid instantiation %q, expected generator:name:type", arg)
+			}
+			reqs = append(reqs, f.request(parts[0], parts[1], parts[2]))
+		}
+	}
+
+	out := strings.TrimSpace(f.out)
+	if out == "" {
+		if len(reqs) > 1 {
+			return nil, generator.Errorf(generator.BadArgument, "-out is required when generating several instantiations")
+		}
+		out = strings.ToLower(reqs[0].Name) + "_gen.go"
+	}
+	if out != stdout && !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+	if out == stdout && (f.tests || f.ported || f.fuzz || f.bench) {
+		return nil, generator.Errorf(generator.BadArgument, "-tests, -upstreamtests, -fuzz and -bench can not be used when writing to standard output")
+	}
+	filename := out
+	if out == stdout {
+		filename = filepath.Join(dir, strings.ToLower(reqs[0].Name)+"_gen.go")
+	}
+
+	if f.pkg != "" {
+		pkg = f.pkg
+	} else if pkg == "" || filepath.Dir(filename) != filepath.Clean(dir) {
+		var err error
+		if pkg, err = generator.PackageName(filepath.Dir(filename)); err != nil {
+			return nil, err
+		}
+	}
+	for i := range reqs {
+		reqs[i].Package = pkg
+		reqs[i].TypeCheck = f.typecheck
+		reqs[i].Strict = f.strict
+		reqs[i].Pos = pos
+	}
+	return []job{{Requests: reqs, Filename: filename, Out: out, Force: f.force, Verify: f.verify, Tests: f.tests, UpstreamTests: f.ported, Fuzz: f.fuzz, Bench: f.bench}}, nil
+}
+
+func (f *flags) request(program, name, typ string) generator.Request {
+	return generator.Request{
+		Options: generator.Options{
+			Name:      strings.TrimSpace(name),
+			Type:      typ,
+			Version:   strings.TrimSpace(f.version),
+			Upstream:  strings.TrimSpace(f.upstream),
+			GoVersion: strings.TrimSpace(f.goversion),
+		},
+		Generator: strings.TrimSpace(program),
+	}
+}
@@ -2,80 +2,182 @@ package main
 
 import (
 	"flag"
-	"io/ioutil"
+	"fmt"
+	"go/token"
+	"io"
 	"os"
-	"os/exec"
-	"path"
-	"strings"
+	"strconv"
+	"text/tabwriter"
 
-	"github.com/joesonw/go-generate/pkg/containerheap"
-	"github.com/joesonw/go-generate/pkg/containerlist"
-	"github.com/joesonw/go-generate/pkg/containerring"
 	"github.com/joesonw/go-generate/pkg/generator"
-	"github.com/joesonw/go-generate/pkg/singleflight"
-	"github.com/joesonw/go-generate/pkg/syncmap"
-)
 
-var (
-	pOut       = flag.String("out", "", "")
-	pName      = flag.String("name", "", "")
-	pGenerator = flag.String("generator", "", "")
-	pVersion   = flag.String("version", "", "")
+	_ "github.com/joesonw/go-generate/pkg/containerheap"
+	_ "github.com/joesonw/go-generate/pkg/containerlist"
+	_ "github.com/joesonw/go-generate/pkg/containerring"
+	_ "github.com/joesonw/go-generate/pkg/singleflight"
+	_ "github.com/joesonw/go-generate/pkg/syncmap"
 )
 
+// command is a go-generate subcommand.
+type command struct {
+	name    string
+	args    string // synopsis of the arguments following the flags.
+	summary string
+	run     func(args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"gen", "generator:name:type...", "generate typed code, the default when no command is given", runGen},
+		{"list", "", "list the available generators and their type arguments", runList},
+		{"check", "generator:name:type...", "report generated files that are out of date, with a diff", runCheck},
+		{"explain", "generator", "show the upstream source, handlers and renames of a generator", runExplain},
+		{"regen", "[packages]", "run every go-generate directive of the packages in process", regen},
+	}
+}
+
 func main() {
-	var g *generator.Generator
-	var err error
-	goPackage := os.Getenv("GOPACKAGE")
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return
+	}
+	cmd := lookupCommand("gen")
+	if c := lookupCommand(args[0]); c != nil {
+		cmd, args = c, args[1:]
+	}
+	die(cmd.run(args))
+}
 
-	flag.Parse()
-	name := strings.TrimSpace(*pName)
-	program := strings.TrimSpace(*pGenerator)
-	version := strings.TrimSpace(*pVersion)
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// usage prints the available commands.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: go-generate <command> [flags] [arguments]\n\ncommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "\t%s\t%s\n", c.name, c.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun \"go-generate <command> -h\" for the flags of a command.\n")
+}
 
-	out := strings.TrimSpace(*pOut)
-	if out == "" {
-		out = strings.ToLower(name) + "_gen.go"
+// newFlagSet returns the flag set of the named command, printing its
+// synopsis and flags on -h.
+func newFlagSet(name string, handling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, handling)
+	fs.Usage = func() {
+		c := lookupCommand(name)
+		if c == nil {
+			c = lookupCommand("gen")
+		}
+		fmt.Fprintf(fs.Output(), "usage: go-generate %s [flags] %s\n\n%s.\n\nflags:\n", c.name, c.args, c.summary)
+		fs.PrintDefaults()
 	}
+	return fs
+}
 
+// runGen generates the instantiations given on the command line or in a
+// manifest.
+func runGen(args []string) error {
+	f, err := parseFlags("gen", args, flag.ExitOnError)
+	if err != nil {
+		return err
+	}
+	if f.list {
+		return runList(nil)
+	}
+	return f.run()
+}
+
+// runCheck compares the instantiations given on the command line or in a
+// manifest with the files on disk.
+func runCheck(args []string) error {
+	f, err := parseFlags("check", args, flag.ExitOnError)
+	if err != nil {
+		return err
+	}
+	f.check = true
+	return f.run()
+}
+
+// run generates or checks the jobs requested by the flags.
+func (f *flags) run() error {
 	cwd, err := os.Getwd()
-	die(err)
-	out = path.Join(cwd, out)
-
-	expr := os.Args[len(os.Args)-1]
-
-	if program == "sync/map" {
-		g, err = syncmap.New(name, goPackage, expr)
-		die(err)
-	} else if program == "container/list" {
-		g, err = containerlist.New(name, goPackage, expr)
-		die(err)
-	} else if program == "container/ring" {
-		g, err = containerring.New(name, goPackage, expr)
-		die(err)
-	} else if program == "container/heap" {
-		g, err = containerheap.New(name, goPackage, expr)
-		die(err)
-	} else if program == "singleflight" {
-		g, err = singleflight.New(name, goPackage, expr, version)
-		die(err)
-	} else {
-		panic(program + " does not exist")
-	}
-
-	die(g.Mutate())
-
-	b, err := g.Generate()
-	die(err)
-
-	die(ioutil.WriteFile(out, b, 0644))
-	die(err)
-
-	die(exec.Command("goimports", "-w", out).Run())
+	if err != nil {
+		return err
+	}
+	if f.watch {
+		return f.watcher(cwd, os.Getenv("GOPACKAGE"), directivePos()).run()
+	}
+	jobs, err := f.jobs(cwd, os.Getenv("GOPACKAGE"), directivePos())
+	if err != nil {
+		return err
+	}
+
+	if f.check {
+		stale, err := checkJobs(os.Stdout, jobs)
+		if err != nil {
+			return err
+		}
+		if stale > 0 {
+			fmt.Fprintf(os.Stderr, "go-generate: %d generated file(s) out of date\n", stale)
+			os.Exit(1)
+		}
+		return nil
+	}
+	return runJobs(jobs)
+}
+
+// runList prints the registered generators.
+func runList(args []string) error {
+	fs := newFlagSet("list", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return generator.Errorf(generator.BadArgument, "list takes no arguments")
+	}
+	printFactories(os.Stdout)
+	return nil
 }
 
+// die reports err and exits. Bad arguments are reported at the position
+// of the go:generate directive when running under go generate.
 func die(err error) {
 	if err != nil {
-		panic(err)
+		if gerr, ok := err.(*generator.Error); ok && gerr.Kind == generator.BadArgument {
+			gerr.At(directivePos())
+		}
+		generator.FailOnErr(err)
+	}
+}
+
+// directivePos returns the position of the go:generate directive which
+// invoked go-generate, if any.
+func directivePos() token.Position {
+	line, _ := strconv.Atoi(os.Getenv("GOLINE"))
+	return token.Position{Filename: os.Getenv("GOFILE"), Line: line}
+}
+
+// printFactories lists the registered generators with their argument syntax.
+func printFactories(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "GENERATOR\tTYPE\tDESCRIPTION\n")
+	for _, f := range generator.Factories() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, f.Args, f.Description)
 	}
+	tw.Flush()
 }
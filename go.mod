@@ -1,8 +1,14 @@
 module github.com/joesonw/go-generate
 
-go 1.13
+go 1.22.0
 
 require (
 	github.com/hashicorp/go-version v1.2.0
-	golang.org/x/tools v0.0.0-20200225022059-a0ec867d517c
+	golang.org/x/tools v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
 )
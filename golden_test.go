@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/joesonw/go-generate/pkg/diff"
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// upstream is a pinned version of the upstream sources. The embedded
+// snapshots are one version; the others are read from the fixtures in
+// testdata/modcache, laid out as the module cache.
+type upstream struct {
+	name      string
+	upstream  string
+	goversion string // for the standard library generators.
+	version   string // for the golang.org/x/sync generators.
+}
+
+var upstreams = []upstream{
+	{name: "embedded", upstream: generator.Embedded},
+	{name: "go1.21.13-sync-v0.1.0", upstream: generator.Live, goversion: "go1.21.13", version: "v0.1.0"},
+}
+
+// goldenCase is an instantiation generated into the package in
+// testdata/target.
+type goldenCase struct {
+	generator string
+	name      string
+	typ       string
+	ports     bool // whether an upstream test compiles with typ.
+}
+
+// goldenCases are the instantiations of every generator, each generated
+// into its own file.
+var goldenCases = []goldenCase{
+	{"sync/map", "IntMap", "map[string]int", false},
+	{"sync/map", "UserMap", "map[Key]*User", false},
+	{"sync/map", "BufferMap", "map[int]*bytes.Buffer", false},
+	{"sync/map", "AnyMap", "map[int]any", true},
+	{"container/list", "IntList", "int", true},
+	{"container/list", "UserList", "*User", false},
+	{"container/ring", "StringRing", "string", false},
+	{"container/ring", "UserRing", "User", false},
+	{"container/heap", "IntHeap", "int", true},
+	{"container/heap", "UserHeap", "*User", false},
+	{"container/heap", "BufferHeap", "*bytes.Buffer", false},
+	{"singleflight", "IntGroup", "map[string]int", true},
+	{"singleflight", "UserGroup", "map[Key]*User", true},
+}
+
+// goldenBundles are instantiations generated together into a single file,
+// by name of the file.
+var goldenBundles = map[string][]goldenCase{
+	"counters": {
+		{"sync/map", "CountMap", "map[int]any", true},
+		{"container/list", "CountList", "int", true},
+		{"container/heap", "CountHeap", "int", true},
+	},
+}
+
+// TestGolden generates every case and bundle, its companion test, its
+// ported upstream tests, its benchmarks and its fuzz tests against every
+// pinned upstream, compares them with testdata/golden and type checks the
+// generated file in its package. The files generated against an upstream
+// are then vetted and their tests, benchmarks and fuzz seeds run, each
+// file in its own copy of the package. Run with -update to rewrite the golden files.
+func TestGolden(t *testing.T) {
+	version := generator.Version
+	t.Cleanup(func() { generator.Version = version })
+	generator.Version = "golden"
+	dir, err := filepath.Abs(filepath.Join("testdata", "target"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modcache, err := filepath.Abs(filepath.Join("testdata", "modcache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]goldenCase{}
+	for _, c := range goldenCases {
+		files[strings.ToLower(c.name)] = []goldenCase{c}
+	}
+	for name, cases := range goldenBundles {
+		files[name] = cases
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cache := generator.NewCache()
+
+	for _, u := range upstreams {
+		u := u
+		t.Run(u.name, func(t *testing.T) {
+			mod := writeTargets(t, dir, names)
+			for _, name := range names {
+				name, cases := name, files[name]
+				t.Run(name, func(t *testing.T) {
+					reqs := make([]generator.Request, len(cases))
+					ports := true
+					for i, c := range cases {
+						reqs[i] = generator.Request{
+							Options: generator.Options{
+								Name:      c.name,
+								Package:   "target",
+								Type:      c.typ,
+								Upstream:  u.upstream,
+								GoVersion: u.goversion,
+							},
+							Generator: c.generator,
+							Cache:     cache,
+							TypeCheck: true,
+							Strict:    true,
+						}
+						if c.generator == "singleflight" {
+							reqs[i].GoVersion, reqs[i].Version = "", u.version
+						}
+						ports = ports && c.ports
+					}
+					filename := filepath.Join(dir, name+"_gen.go")
+					// GOMODCACHE points to the fixtures in modcache while
+					// generating only. Verify runs without it: the go command
+					// loading the packages imported by the output would
+					// download the dependencies of the module to the fixtures.
+					var out map[string][]byte
+					t.Run("generate", func(t *testing.T) {
+						t.Setenv("GOMODCACHE", modcache)
+						var err error
+						if out, err = generate(filename, reqs, ports); err != nil {
+							t.Fatal(err)
+						}
+					})
+					if out == nil {
+						return
+					}
+					if err := generator.Verify(filename, out[".go"], reqs...); err != nil {
+						t.Error(err)
+					}
+					golden := filepath.Join("testdata", "golden", u.name, name)
+					for suffix, b := range out {
+						compareGolden(t, golden+suffix+".golden", b)
+						if err := ioutil.WriteFile(filepath.Join(mod, name, name+"_gen"+suffix), b, 0644); err != nil {
+							t.Fatal(err)
+						}
+					}
+				})
+			}
+			if !t.Failed() {
+				goCommand(t, mod, "vet", "./...")
+				goCommand(t, mod, "test", "-count=1", "-bench=.", "-benchtime=1x", "./...")
+			}
+		})
+	}
+}
+
+// writeTargets writes a module to a temporary directory, with a copy of
+// the package in dir in a directory of every name. The module requires the
+// golang.org/x/sync of this module, for the benchmarks comparing
+// singleflight with it. It returns the directory of the module.
+func writeTargets(t *testing.T, dir string, names []string) string {
+	t.Helper()
+	mod := t.TempDir()
+	sum, err := ioutil.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"go.mod": []byte("module example.com/target\n\ngo 1.22\n\nrequire golang.org/x/sync v0.11.0\n"),
+		"go.sum": sum,
+	}
+	sources, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range sources {
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			files[filepath.Join(name, filepath.Base(src))] = b
+		}
+	}
+	for name, b := range files {
+		path := filepath.Join(mod, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mod
+}
+
+// goCommand runs the go command with args in dir, without network access,
+// and fails the test if it fails.
+func goCommand(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// compareGolden compares b with the golden file at name, or rewrites the
+// file with -update.
+func compareGolden(t *testing.T, name string, b []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%v; run go test -run TestGolden -update", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("generated code differs from %s:\n%s", name, diff.Unified(name, "generated", want, b))
+	}
+}
+
+// generate returns the file generated for reqs at filename, its companion
+// test, ported upstream tests, benchmarks and fuzz tests, if the
+// generators write them, by suffix of their golden files. If ports is
+// false, porting the upstream tests must warn as none compiles, and fail
+// for strict requests.
+func generate(filename string, reqs []generator.Request, ports bool) (map[string][]byte, error) {
+	b, err := generator.Bundle(filename, reqs...)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{".go": b}
+	if files["_test.go"], err = generator.BundleTests(generator.TestFile(filename), reqs...); err != nil {
+		return nil, err
+	}
+	lenient := make([]generator.Request, len(reqs))
+	for i, r := range reqs {
+		r.Strict = false
+		lenient[i] = r
+	}
+	ported, err := generator.PortTests(filename, b, lenient...)
+	switch {
+	case ported == nil || ports && err != nil:
+		return nil, fmt.Errorf("porting the upstream tests: %v", err)
+	case !ports && generator.KindOf(err) != generator.BadArgument:
+		return nil, fmt.Errorf("porting the upstream tests: got %v, want a bad argument error", err)
+	}
+	files["_upstream_test.go"] = ported
+	if _, err := generator.PortTests(filename, b, reqs...); ports != (err == nil) {
+		return nil, fmt.Errorf("porting the upstream tests of strict requests: got %v", err)
+	}
+	if files["_bench_test.go"], err = generator.BundleBenchmarks(generator.BenchFile(filename), reqs...); err != nil {
+		return nil, err
+	}
+	if reqs[0].Generator != "singleflight" {
+		if files["_fuzz_test.go"], err = generator.BundleFuzzTests(generator.FuzzTestFile(filename), reqs...); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/joesonw/go-generate/pkg/diff"
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// stdout is the output path writing the generated file to standard output.
+const stdout = "-"
+
+// Modes of -verify, what to do when the generated file does not compile.
+const (
+	verifyError = "error" // report the error and do not write the file.
+	verifyWarn  = "warn"  // write the file and report the error.
+	verifyOff   = "off"   // do not type check the generated file.
+)
+
+// job is a set of instantiations generated into a single output file.
+type job struct {
+	Requests []generator.Request
+	Filename string // path used to resolve imports of the generated file.
+	Out      string // path of the generated file, or stdout.
+	Force    bool   // regenerate even if the file is up to date.
+	Verify   string // verifyError, verifyWarn or verifyOff.
+	Tests    bool   // also generate the companion test next to Out.
+	// UpstreamTests also ports the upstream tests next to Out.
+	UpstreamTests bool
+	Fuzz          bool // also generate the fuzz tests next to Out.
+	Bench         bool // also generate the benchmarks next to Out.
+}
+
+// generate runs the whole pipeline in memory and returns the final file,
+// reading upstream sources through cache when it is not nil.
+func (j job) generate(cache *generator.Cache) ([]byte, error) {
+	return generator.Bundle(j.Filename, j.requests(cache)...)
+}
+
+// requests returns the requests of the job sharing cache, resolving their
+// type arguments in the package of the job's file.
+func (j job) requests(cache *generator.Cache) []generator.Request {
+	reqs := make([]generator.Request, len(j.Requests))
+	for i, r := range j.Requests {
+		r.Cache = cache
+		r.Filename = j.Filename
+		reqs[i] = r
+	}
+	return reqs
+}
+
+// file is a generated file and its output path.
+type file struct {
+	Out string
+	Src []byte
+}
+
+// outs returns the output paths of the job's files.
+func (j job) outs() []string {
+	outs := []string{j.Out}
+	if j.Tests {
+		outs = append(outs, generator.TestFile(j.Out))
+	}
+	if j.UpstreamTests {
+		outs = append(outs, generator.UpstreamTestFile(j.Out))
+	}
+	if j.Fuzz {
+		outs = append(outs, generator.FuzzTestFile(j.Out))
+	}
+	if j.Bench {
+		outs = append(outs, generator.BenchFile(j.Out))
+	}
+	return outs
+}
+
+// files generates the job's file and the test files requested with it.
+func (j job) files(cache *generator.Cache) ([]file, error) {
+	b, err := j.generate(cache)
+	if err != nil {
+		return nil, err
+	}
+	files := []file{{j.Out, b}}
+	if j.Tests {
+		test, err := generator.BundleTests(generator.TestFile(j.Filename), j.requests(cache)...)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{generator.TestFile(j.Out), test})
+	}
+	if j.UpstreamTests {
+		ported, err := generator.PortTests(j.Filename, b, j.requests(cache)...)
+		if ported == nil {
+			return nil, err
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		files = append(files, file{generator.UpstreamTestFile(j.Out), ported})
+	}
+	if j.Fuzz {
+		fuzz, err := generator.BundleFuzzTests(generator.FuzzTestFile(j.Filename), j.requests(cache)...)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{generator.FuzzTestFile(j.Out), fuzz})
+	}
+	if j.Bench {
+		bench, err := generator.BundleBenchmarks(generator.BenchFile(j.Filename), j.requests(cache)...)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{generator.BenchFile(j.Out), bench})
+	}
+	return files, nil
+}
+
+// upToDate reports whether the job's files on disk record the same inputs.
+func (j job) upToDate() (bool, error) {
+	for _, out := range j.outs() {
+		old, err := ioutil.ReadFile(out)
+		if err != nil {
+			return false, nil
+		}
+		if ok, err := generator.UpToDate(old, j.Requests...); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// run generates the job's file and the test files requested with it, and
+// writes them to its output. It reports whether the files were written,
+// which they are not when the existing files already record the same
+// inputs.
+func (j job) run(cache *generator.Cache) (bool, error) {
+	if !j.Force && j.Out != stdout {
+		if ok, err := j.upToDate(); err != nil || ok {
+			return false, err
+		}
+	}
+	files, err := j.files(cache)
+	if err != nil {
+		return false, err
+	}
+	var verr error
+	if j.Verify != verifyOff {
+		verr = generator.Verify(j.Filename, files[0].Src, j.requests(cache)...)
+		if verr != nil && j.Verify != verifyWarn {
+			return false, verr
+		}
+	}
+	if j.Out == stdout {
+		_, err = os.Stdout.Write(files[0].Src)
+	} else {
+		for _, f := range files {
+			if err = ioutil.WriteFile(f.Out, f.Src, 0644); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return false, &generator.Error{Kind: generator.WriteFailed, Msg: "write generated file", Err: err}
+	}
+	if verr != nil {
+		fmt.Fprintln(os.Stderr, verr)
+	}
+	j.notes()
+	return true, nil
+}
+
+// notes prints the choices the generators made on behalf of the user to
+// standard error, one line each.
+func (j job) notes() {
+	for _, r := range j.Requests {
+		notes, err := r.Notes()
+		if err != nil {
+			continue // reported when generating.
+		}
+		for _, n := range notes {
+			prefix := ""
+			if r.Pos.IsValid() || r.Pos.Filename != "" {
+				prefix = r.Pos.String() + ": "
+			}
+			fmt.Fprintf(os.Stderr, "%sgo-generate: %s %s: %s\n", prefix, r.Generator, r.Name, n)
+		}
+	}
+}
+
+// check generates the job's files in memory and returns a unified diff
+// against the files on disk, or "" when they are up to date.
+func (j job) check(cache *generator.Cache) (string, error) {
+	if j.Out == stdout {
+		return "", generator.Errorf(generator.BadArgument, "can not check output written to stdout")
+	}
+	files, err := j.files(cache)
+	if err != nil {
+		return "", err
+	}
+	var d string
+	for _, f := range files {
+		fd, err := diffFile(f.Out, f.Src)
+		if err != nil {
+			return "", err
+		}
+		d += fd
+	}
+	return d, nil
+}
+
+// diffFile returns a unified diff from the file at name to b.
+func diffFile(name string, b []byte) (string, error) {
+	old, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return "", &generator.Error{Kind: generator.SourceNotFound, Msg: "read generated file", Err: err}
+	}
+	base := filepath.Base(name)
+	return diff.Unified("a/"+base, "b/"+base, old, b), nil
+}
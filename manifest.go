@@ -0,0 +1,62 @@
+package main
+
+import (
+	"go/token"
+	"path/filepath"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+	"github.com/joesonw/go-generate/pkg/manifest"
+)
+
+// manifestJobs turns every instance of the manifest at path into a job.
+func manifestJobs(path string) ([]job, error) {
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	// instances sharing an output file are generated into a single file.
+	var jobs []job
+	index := map[string]int{}
+	for _, inst := range m.Generate {
+		out := m.OutOf(inst)
+		pkg := m.PackageOf(inst)
+		if pkg == "" {
+			if pkg, err = generator.PackageName(filepath.Dir(out)); err != nil {
+				return nil, err
+			}
+		}
+		i, ok := index[out]
+		if !ok {
+			i = len(jobs)
+			index[out] = i
+			jobs = append(jobs, job{Filename: out, Out: out})
+		}
+		jobs[i].Requests = append(jobs[i].Requests, generator.Request{
+			Options: generator.Options{
+				Name:      inst.Name,
+				Package:   pkg,
+				Type:      inst.Type,
+				Version:   inst.Version,
+				Upstream:  inst.Upstream,
+				GoVersion: inst.GoVersion,
+			},
+			Generator: inst.Generator,
+			Pos:       token.Position{Filename: path, Line: inst.Line},
+			TypeCheck: inst.TypeCheck == nil || *inst.TypeCheck,
+			Strict:    inst.Strict != nil && *inst.Strict,
+		})
+	}
+	return jobs, nil
+}
+
+// runJobs generates every job, sharing the parsed upstream sources
+// between them.
+func runJobs(jobs []job) error {
+	cache := generator.NewCache()
+	for _, j := range jobs {
+		if _, err := j.run(cache); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// TestManifest generates two instantiations of a manifest at the module
+// root into a single file of a package below it, along with its tests.
+func TestManifest(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"go-generate.yaml": `generate:
+  - generator: sync/map
+    name: ItemMap
+    type: map[string]*Item
+    out: sub/maps_gen.go
+  - generator: container/list
+    name: item
+    type: Item
+    out: sub/maps_gen.go
+`,
+		"sub/item.go": `package sub
+
+// Item is declared in the package of the generated file only.
+type Item struct{ Name string }
+`,
+	})
+	chdir(t, root)
+	f, err := parseFlags("gen", []string{"-config", "go-generate.yaml", "-tests"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := f.jobs(root, "", token.Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs; want 1", len(jobs))
+	}
+	if _, err := jobs[0].run(generator.NewCache()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"maps_gen.go", "maps_gen_test.go"} {
+		if _, err := os.Stat(filepath.Join(root, "sub", name)); err != nil {
+			t.Error(err)
+		}
+	}
+	goCommand(t, root, "vet", "./...")
+	goCommand(t, root, "test", "-count=1", "./...")
+}
+
+// TestManifestFlags checks that the flags given on the command line, and
+// only those, override the instances of a manifest, and that the requests
+// are positioned at their instance.
+func TestManifestFlags(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"go-generate.yaml": `package: m
+generate:
+  - generator: sync/map
+    name: IntMap
+    type: map[string]int
+  - generator: sync/map
+    name: LiveMap
+    type: map[string]int
+    upstream: live
+    goversion: go1.21.13
+    typecheck: false
+    strict: true
+`,
+	})
+	for _, c := range []struct {
+		args []string
+		want [2]generator.Request
+	}{
+		{nil, [2]generator.Request{
+			{Options: generator.Options{Upstream: generator.Embedded}, TypeCheck: true},
+			{Options: generator.Options{Upstream: generator.Live, GoVersion: "go1.21.13"}, Strict: true},
+		}},
+		{[]string{"-upstream", "embedded", "-goversion", "", "-typecheck", "-strict=false"}, [2]generator.Request{
+			{Options: generator.Options{Upstream: generator.Embedded}, TypeCheck: true},
+			{Options: generator.Options{Upstream: generator.Embedded}, TypeCheck: true},
+		}},
+		{[]string{"-strict"}, [2]generator.Request{
+			{Options: generator.Options{Upstream: generator.Embedded}, TypeCheck: true, Strict: true},
+			{Options: generator.Options{Upstream: generator.Live, GoVersion: "go1.21.13"}, Strict: true},
+		}},
+	} {
+		f, err := parseFlags("gen", append([]string{"-config", "go-generate.yaml"}, c.args...), flag.ContinueOnError)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs, err := f.jobs(root, "", token.Position{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 2 {
+			t.Fatalf("got %d jobs; want 2", len(jobs))
+		}
+		for i, want := range c.want {
+			r := jobs[i].Requests[0]
+			if r.Upstream != want.Upstream || r.GoVersion != want.GoVersion || r.TypeCheck != want.TypeCheck || r.Strict != want.Strict {
+				t.Errorf("%v: %s has upstream %q, goversion %q, typecheck %v, strict %v; want %q, %q, %v, %v",
+					c.args, r.Name, r.Upstream, r.GoVersion, r.TypeCheck, r.Strict, want.Upstream, want.GoVersion, want.TypeCheck, want.Strict)
+			}
+			if line := []int{3, 6}[i]; r.Pos.Line != line || filepath.Base(r.Pos.Filename) != "go-generate.yaml" {
+				t.Errorf("%s is at %s; want go-generate.yaml:%d", r.Name, r.Pos, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package containerheap
+
+import "github.com/joesonw/go-generate/pkg/generator"
+
+// bench benchmarks Push followed by Pop on heaps of random values ordered
+// by random priorities, through the generated functions and through
+// container/heap.
+const bench = `import (
+	"container/heap"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+{{template "heap" .Heap}}
+
+{{template "heap" .AnyHeap}}
+
+// bench{{.Name}}Values returns n random values and priorities.
+func bench{{.Name}}Values(t testing.TB, n int) ([]{{.Type}}, []int) {
+	rnd := rand.New(rand.NewSource(1))
+	value := {{template "value" .Type}}
+	values := make([]{{.Type}}, n)
+	for i := range values {
+		values[i] = value()
+	}
+	return values, rnd.Perm(n)
+}
+
+func Benchmark{{.Name}}(b *testing.B) {
+	values, priorities := bench{{.Name}}Values(b, 1024)
+
+	// the heaps hold half of the values before each push.
+	b.Run("PushPop/{{.Name}}", func(b *testing.B) {
+		h := &bench{{.Name}}{}
+		for i := 0; i < len(values)/2; i++ {
+			h.next = priorities[i]
+			Push(h, values[i])
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			j := i % len(values)
+			h.next = priorities[j]
+			Push(h, values[j])
+			Pop(h)
+		}
+	})
+	b.Run("PushPop/heap", func(b *testing.B) {
+		h := &benchAny{{.Name}}{}
+		for i := 0; i < len(values)/2; i++ {
+			h.next = priorities[i]
+			heap.Push(h, values[i])
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			j := i % len(values)
+			h.next = priorities[j]
+			heap.Push(h, values[j])
+			heap.Pop(h)
+		}
+	})
+}
+`
+
+// Bench returns the benchmarks of the instantiation and of container/heap.
+func (g *Generator) Bench() string {
+	return generator.RenderTest(bench, map[string]interface{}{
+		"Name":    g.name,
+		"Type":    g.typ,
+		"Heap":    g.heap("bench"),
+		"AnyHeap": g.anyHeap("bench"),
+	}, heapTemplate)
+}
@@ -1,21 +1,31 @@
 package containerheap
 
 import (
-	"fmt"
 	"go/ast"
-	"runtime"
 
 	"github.com/joesonw/go-generate/pkg/generator"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
-func New(name, pkg, typ string) (g *generator.Generator, err error) {
+func init() {
+	generator.Register(generator.Factory{
+		Name:        "container/heap",
+		Description: "typed copy of container/heap",
+		Args:        "T",
+		New:         New,
+	})
+}
+
+// New returns the generator of the instantiation described by o.
+func New(o generator.Options) (g *generator.Generator, err error) {
+	defer generator.Catch(&err)
 	gen := &Generator{
-		name: name,
+		name: o.Name,
 	}
-	g, err = generator.New(pkg, fmt.Sprintf("%s/src/container/heap/heap.go", runtime.GOROOT()), gen)
+	g, err = generator.New(o.Package, generator.StdSource(o, "src/container/heap/heap.go"), gen)
 
-	gen.typ = typ
+	generator.ParseType(o.Type)
+	gen.typ = o.Type
 	gen.Generator = g
 	return g, err
 }
@@ -37,11 +47,7 @@ func (g *Generator) Types() map[string]func(*ast.TypeSpec) {
 			astutil.Apply(n, func(c *astutil.Cursor) bool {
 				n := c.Node()
 				if _, ok := n.(*ast.Ident); !matched && ok {
-					name := g.typ + "Interface"
-					if name[0] == '*' {
-						name = name[1:]
-					}
-					c.Replace(generator.Expr(name, n.Pos()))
+					c.Replace(generator.Expr(g.iface(), n.Pos()))
 					matched = true
 				}
 				if f, ok := n.(*ast.FuncType); ok {
@@ -72,9 +78,64 @@ func (g *Generator) Funcs() map[string]func(*ast.FuncDecl) {
 }
 
 func (g *Generator) Mutate() error {
+	g.Rename(map[string]string{"Interface": g.iface()})
 	return nil
 }
 
+// iface returns the name of the typed heap.Interface, named after the
+// instantiation since the type argument may not be an identifier.
+func (g *Generator) iface() string {
+	return g.name + "Interface"
+}
+
+// heapTemplate defines the "heap" template of the tests, fuzz tests and
+// benchmarks. Applied to a map of a Name, a Type and an Iface, it declares
+// the type Name, a heap of values of Type ordered by the priority they
+// were pushed with, implementing Iface.
+const heapTemplate = `{{define "heap"}}// {{.Name}} is a heap of values ordered by the priority they were
+// pushed with.
+type {{.Name}} struct {
+	values     []{{.Type}}
+	priorities []int
+	next       int // priority of the next pushed value.
+}
+
+var _ {{.Iface}} = (*{{.Name}})(nil)
+
+func (h *{{.Name}}) Len() int           { return len(h.values) }
+func (h *{{.Name}}) Less(i, j int) bool { return h.priorities[i] < h.priorities[j] }
+
+func (h *{{.Name}}) Swap(i, j int) {
+	h.values[i], h.values[j] = h.values[j], h.values[i]
+	h.priorities[i], h.priorities[j] = h.priorities[j], h.priorities[i]
+}
+
+func (h *{{.Name}}) Push(x {{.Type}}) {
+	h.values = append(h.values, x)
+	h.priorities = append(h.priorities, h.next)
+}
+
+func (h *{{.Name}}) Pop() {{.Type}} {
+	n := len(h.values) - 1
+	x := h.values[n]
+	h.values, h.priorities = h.values[:n], h.priorities[:n]
+	return x
+}{{end}}`
+
+// heap returns the data of the "heap" test template declaring the heap
+// named prefix followed by the name of the instantiation, implementing the
+// typed heap.Interface.
+func (g *Generator) heap(prefix string) map[string]string {
+	return map[string]string{"Name": prefix + g.name, "Type": g.typ, "Iface": g.iface()}
+}
+
+// anyHeap returns the data of the "heap" test template declaring the heap
+// of the same name with Any after prefix, implementing heap.Interface to
+// compare the instantiation with container/heap.
+func (g *Generator) anyHeap(prefix string) map[string]string {
+	return map[string]string{"Name": prefix + "Any" + g.name, "Type": "interface{}", "Iface": "heap.Interface"}
+}
+
 func (g *Generator) replaceFunctionResult(f *ast.FuncDecl) {
 	generator.ReplaceIface(f.Type.Results.List[0], g.typ)
 }
@@ -0,0 +1,88 @@
+package containerheap
+
+import "github.com/joesonw/go-generate/pkg/generator"
+
+// fuzz applies the operations decoded from the fuzz input to two heaps of
+// values ordered by priority, one through the generated functions and one
+// through container/heap, and compares their slices after each operation.
+const fuzz = `import (
+	"container/heap"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+{{template "heap" .Heap}}
+
+{{template "heap" .AnyHeap}}
+
+func Fuzz{{.Name}}(f *testing.F) {
+	f.Add(int64(1), []byte{0, 8, 16, 24, 1, 32, 2, 43, 19, 4, 0, 1, 1, 1})
+	f.Fuzz(func(t *testing.T, seed int64, ops []byte) {
+		rnd := rand.New(rand.NewSource(seed))
+		value := {{template "value" .Type}}
+
+		h, want := &fuzz{{.Name}}{}, &fuzzAny{{.Name}}{}
+		for _, op := range ops {
+			p, i := int(op>>3), 0
+			if h.Len() > 0 {
+				i = p % h.Len()
+			}
+			switch op & 7 {
+			case 0:
+				v := value()
+				h.next, want.next = p, p
+				Push(h, v)
+				heap.Push(want, v)
+			case 1:
+				if h.Len() == 0 {
+					continue
+				}
+				if v, w := Pop(h), heap.Pop(want); !reflect.DeepEqual(v, w) {
+					t.Fatalf("Pop() = %v; container/heap returned %v", v, w)
+				}
+			case 2:
+				if h.Len() == 0 {
+					continue
+				}
+				if v, w := Remove(h, i), heap.Remove(want, i); !reflect.DeepEqual(v, w) {
+					t.Fatalf("Remove(%d) = %v; container/heap returned %v", i, v, w)
+				}
+			case 3:
+				if h.Len() > 0 {
+					h.priorities[i], want.priorities[i] = p, p
+					Fix(h, i)
+					heap.Fix(want, i)
+				}
+			default:
+				if h.Len() > 0 {
+					h.priorities[i], want.priorities[i] = p, p
+				}
+				Init(h)
+				heap.Init(want)
+			}
+
+			if !reflect.DeepEqual(h.priorities, want.priorities) {
+				t.Fatalf("priorities %v; container/heap has %v", h.priorities, want.priorities)
+			}
+			for i, v := range h.values {
+				if !reflect.DeepEqual(v, want.values[i]) {
+					t.Fatalf("value %d = %v; container/heap has %v", i, v, want.values[i])
+				}
+			}
+		}
+	})
+}
+`
+
+// Fuzz returns the fuzz test comparing the instantiation with the
+// functions of container/heap.
+func (g *Generator) Fuzz() string {
+	return generator.RenderTest(fuzz, map[string]interface{}{
+		"Name":    g.name,
+		"Type":    g.typ,
+		"Heap":    g.heap("fuzz"),
+		"AnyHeap": g.anyHeap("fuzz"),
+	}, heapTemplate)
+}
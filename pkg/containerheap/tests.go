@@ -0,0 +1,50 @@
+package containerheap
+
+import "github.com/joesonw/go-generate/pkg/generator"
+
+// test pushes random values with shuffled priorities on a heap ordered by
+// priority, and pops them back in order.
+const test = `import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+{{template "heap" .Heap}}
+
+func Test{{.Name}}(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	value := {{template "value" .Type}}
+
+	h := &test{{.Name}}{}
+	want := make([]{{.Type}}, 20)
+	for _, p := range rnd.Perm(len(want)) {
+		want[p] = value()
+		h.next = p
+		Push(h, want[p])
+	}
+	for i := range want {
+		if got := Pop(h); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Pop() = %v; want %v, pushed with priority %d", got, want[i], i)
+		}
+	}
+	if h.Len() != 0 {
+		t.Errorf("Len() = %d after popping every value; want 0", h.Len())
+	}
+}
+`
+
+// Test returns the companion test of the instantiation.
+func (g *Generator) Test() string {
+	return generator.RenderTest(test, map[string]interface{}{
+		"Name": g.name,
+		"Type": g.typ,
+		"Heap": g.heap("test"),
+	}, heapTemplate)
+}
+
+// UpstreamTests returns the tests of container/heap, which use int values.
+func (g *Generator) UpstreamTests() ([]string, string) {
+	return []string{"src/container/heap/heap_test.go"}, g.typ
+}
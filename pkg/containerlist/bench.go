@@ -0,0 +1,80 @@
+package containerlist
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// bench benchmarks PushBack, and PushBack followed by Remove, on the list
+// and on a list.List with the same random values.
+const bench = `import (
+	"container/list"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// bench{{.List}}Values returns n random values.
+func bench{{.List}}Values(t testing.TB, n int) []{{.Type}} {
+	rnd := rand.New(rand.NewSource(1))
+	value := {{template "value" .Type}}
+	values := make([]{{.Type}}, n)
+	for i := range values {
+		values[i] = value()
+	}
+	return values
+}
+
+func Benchmark{{.List}}(b *testing.B) {
+	values := bench{{.List}}Values(b, 1024)
+
+	// the lists are emptied when they hold every value.
+	b.Run("PushBack/{{.List}}", func(b *testing.B) {
+		l := {{.New}}()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if l.Len() == len(values) {
+				l.Init()
+			}
+			l.PushBack(values[i%len(values)])
+		}
+	})
+	b.Run("PushBack/list.List", func(b *testing.B) {
+		l := list.New()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if l.Len() == len(values) {
+				l.Init()
+			}
+			l.PushBack(values[i%len(values)])
+		}
+	})
+
+	b.Run("PushBackRemove/{{.List}}", func(b *testing.B) {
+		l := {{.New}}()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Remove(l.PushBack(values[i%len(values)]))
+		}
+	})
+	b.Run("PushBackRemove/list.List", func(b *testing.B) {
+		l := list.New()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Remove(l.PushBack(values[i%len(values)]))
+		}
+	})
+}
+`
+
+// Bench returns the benchmarks of the instantiation and of list.List.
+func (g *Generator) Bench() string {
+	name := strings.Title(g.name)
+	return generator.RenderTest(bench, map[string]string{
+		"List": name + "List",
+		"New":  "New" + name + "List",
+		"Type": g.typ,
+	})
+}
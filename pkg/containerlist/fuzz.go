@@ -0,0 +1,113 @@
+package containerlist
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// fuzz applies the operations decoded from the fuzz input to the list and
+// to a list.List, keeping their elements in parallel slices to use them as
+// marks, and compares the lists in both directions after each operation.
+const fuzz = `import (
+	"container/list"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func Fuzz{{.List}}(f *testing.F) {
+	f.Add(int64(1), []byte{0, 1, 5, 14, 6, 3, 12, 7, 4, 2, 10, 0, 8, 18})
+	f.Fuzz(func(t *testing.T, seed int64, ops []byte) {
+		rnd := rand.New(rand.NewSource(seed))
+		value := {{template "value" .Type}}
+
+		l, want := {{.New}}(), list.New()
+		var elems []*{{.Element}}
+		var wants []*list.Element
+		add := func(e *{{.Element}}, w *list.Element) {
+			elems, wants = append(elems, e), append(wants, w)
+		}
+		for _, op := range ops {
+			i, j := 0, 0
+			if len(elems) > 0 {
+				i, j = int(op>>3)%len(elems), int(op>>5)%len(elems)
+			}
+			switch op & 7 {
+			case 0:
+				v := value()
+				add(l.PushBack(v), want.PushBack(v))
+			case 1:
+				v := value()
+				add(l.PushFront(v), want.PushFront(v))
+			case 2:
+				if len(elems) == 0 {
+					continue
+				}
+				if v, w := l.Remove(elems[i]), want.Remove(wants[i]); !reflect.DeepEqual(v, w) {
+					t.Fatalf("Remove() = %v; list.List returned %v", v, w)
+				}
+				elems, wants = append(elems[:i], elems[i+1:]...), append(wants[:i], wants[i+1:]...)
+			case 3:
+				if len(elems) > 0 {
+					l.MoveToFront(elems[i])
+					want.MoveToFront(wants[i])
+				}
+			case 4:
+				if len(elems) > 0 {
+					l.MoveToBack(elems[i])
+					want.MoveToBack(wants[i])
+				}
+			case 5:
+				if len(elems) > 0 {
+					v := value()
+					add(l.InsertBefore(v, elems[i]), want.InsertBefore(v, wants[i]))
+				}
+			case 6:
+				if len(elems) > 0 {
+					l.MoveAfter(elems[i], elems[j])
+					want.MoveAfter(wants[i], wants[j])
+				}
+			default:
+				if len(elems) > 0 {
+					l.MoveBefore(elems[i], elems[j])
+					want.MoveBefore(wants[i], wants[j])
+				}
+			}
+
+			if l.Len() != want.Len() {
+				t.Fatalf("Len() = %d; list.List has %d elements", l.Len(), want.Len())
+			}
+			e, w := l.Front(), want.Front()
+			for ; e != nil && w != nil; e, w = e.Next(), w.Next() {
+				if !reflect.DeepEqual(e.Value, w.Value) {
+					t.Fatalf("element %v; list.List has %v", e.Value, w.Value)
+				}
+			}
+			if e != nil || w != nil {
+				t.Fatalf("lists of different lengths walking forward")
+			}
+			for e, w = l.Back(), want.Back(); e != nil && w != nil; e, w = e.Prev(), w.Prev() {
+				if !reflect.DeepEqual(e.Value, w.Value) {
+					t.Fatalf("element %v walking backward; list.List has %v", e.Value, w.Value)
+				}
+			}
+			if e != nil || w != nil {
+				t.Fatalf("lists of different lengths walking backward")
+			}
+		}
+	})
+}
+`
+
+// Fuzz returns the fuzz test comparing the instantiation with list.List.
+func (g *Generator) Fuzz() string {
+	name := strings.Title(g.name)
+	return generator.RenderTest(fuzz, map[string]string{
+		"List":    name + "List",
+		"Element": name + "Element",
+		"New":     "New" + name + "List",
+		"Type":    g.typ,
+	})
+}
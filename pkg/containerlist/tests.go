@@ -0,0 +1,74 @@
+package containerlist
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// test exercises PushBack, PushFront and Remove with random values.
+const test = `import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func Test{{.List}}(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	value := {{template "value" .Type}}
+
+	l := {{.New}}()
+	var want []{{.Type}}
+	var elems []*{{.Element}}
+	for i := 0; i < 10; i++ {
+		v := value()
+		want = append(want, v)
+		elems = append(elems, l.PushBack(v))
+	}
+	front := value()
+	l.PushFront(front)
+	want = append([]{{.Type}}{front}, want...)
+
+	check := func(want []{{.Type}}) {
+		t.Helper()
+		if l.Len() != len(want) {
+			t.Fatalf("Len() = %d; want %d", l.Len(), len(want))
+		}
+		i := 0
+		for e := l.Front(); e != nil; e = e.Next() {
+			if !reflect.DeepEqual(e.Value, want[i]) {
+				t.Errorf("element %d = %v; want %v", i, e.Value, want[i])
+			}
+			i++
+		}
+	}
+	check(want)
+
+	for len(elems) > 0 {
+		i := rnd.Intn(len(elems))
+		if v := l.Remove(elems[i]); !reflect.DeepEqual(v, want[i+1]) {
+			t.Errorf("Remove(element %d) = %v; want %v", i+1, v, want[i+1])
+		}
+		elems = append(elems[:i], elems[i+1:]...)
+		want = append(want[:i+1], want[i+2:]...)
+		check(want)
+	}
+}
+`
+
+// Test returns the companion test of the instantiation.
+func (g *Generator) Test() string {
+	name := strings.Title(g.name)
+	return generator.RenderTest(test, map[string]string{
+		"List":    name + "List",
+		"Element": name + "Element",
+		"New":     "New" + name + "List",
+		"Type":    g.typ,
+	})
+}
+
+// UpstreamTests returns the tests of container/list, which use int values.
+func (g *Generator) UpstreamTests() ([]string, string) {
+	return []string{"src/container/list/list_test.go"}, g.typ
+}
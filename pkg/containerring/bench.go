@@ -0,0 +1,86 @@
+package containerring
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// bench benchmarks setting the values of the ring while moving forward,
+// and Do, on the ring and on a ring.Ring with the same random values.
+const bench = `import (
+	"container/ring"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// bench{{.Ring}}Values returns n random values.
+func bench{{.Ring}}Values(t testing.TB, n int) []{{.Type}} {
+	rnd := rand.New(rand.NewSource(1))
+	value := {{template "value" .Type}}
+	values := make([]{{.Type}}, n)
+	for i := range values {
+		values[i] = value()
+	}
+	return values
+}
+
+func Benchmark{{.Ring}}(b *testing.B) {
+	values := bench{{.Ring}}Values(b, 1024)
+
+	b.Run("SetValue/{{.Ring}}", func(b *testing.B) {
+		r := New(len(values))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r.Value = values[i%len(values)]
+			r = r.Next()
+		}
+	})
+	b.Run("SetValue/ring.Ring", func(b *testing.B) {
+		r := ring.New(len(values))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r.Value = values[i%len(values)]
+			r = r.Next()
+		}
+	})
+
+	// each iteration visits every value.
+	b.Run("Do/{{.Ring}}", func(b *testing.B) {
+		r := New(len(values))
+		for _, v := range values {
+			r.Value = v
+			r = r.Next()
+		}
+		n := 0
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.Do(func({{.Type}}) { n++ })
+		}
+	})
+	b.Run("Do/ring.Ring", func(b *testing.B) {
+		r := ring.New(len(values))
+		for _, v := range values {
+			r.Value = v
+			r = r.Next()
+		}
+		n := 0
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.Do(func(interface{}) { n++ })
+		}
+	})
+}
+`
+
+// Bench returns the benchmarks of the instantiation and of ring.Ring.
+func (g *Generator) Bench() string {
+	return generator.RenderTest(bench, map[string]string{
+		"Ring": strings.Title(g.name) + "Ring",
+		"Type": g.typ,
+	})
+}
@@ -1,21 +1,31 @@
 package containerring
 
 import (
-	"fmt"
 	"go/ast"
-	"runtime"
 	"strings"
 
 	"github.com/joesonw/go-generate/pkg/generator"
 )
 
-func New(name, pkg, typ string) (g *generator.Generator, err error) {
+func init() {
+	generator.Register(generator.Factory{
+		Name:        "container/ring",
+		Description: "typed copy of container/ring",
+		Args:        "T",
+		New:         New,
+	})
+}
+
+// New returns the generator of the instantiation described by o.
+func New(o generator.Options) (g *generator.Generator, err error) {
+	defer generator.Catch(&err)
 	gen := &Generator{
-		name: name,
+		name: o.Name,
 	}
-	g, err = generator.New(pkg, fmt.Sprintf("%s/src/container/ring/ring.go", runtime.GOROOT()), gen)
+	g, err = generator.New(o.Package, generator.StdSource(o, "src/container/ring/ring.go"), gen)
 
-	gen.typ = typ
+	generator.ParseType(o.Type)
+	gen.typ = o.Type
 	gen.Generator = g
 	return g, err
 }
@@ -0,0 +1,92 @@
+package containerring
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// fuzz applies the operations decoded from the fuzz input to the ring and
+// to a ring.Ring, moving both the same way, and compares them with Do
+// after each operation. Elements never set hold the zero value, nil in the
+// ring.Ring.
+const fuzz = `import (
+	"container/ring"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func Fuzz{{.Ring}}(f *testing.F) {
+	f.Add(int64(1), []byte{0, 1, 0, 11, 0, 4, 2, 12, 5, 3, 21, 0, 1, 29})
+	f.Fuzz(func(t *testing.T, seed int64, ops []byte) {
+		rnd := rand.New(rand.NewSource(seed))
+		value := {{template "value" .Type}}
+		equal := func(v {{.Type}}, w interface{}) bool {
+			if w == nil {
+				var zero {{.Type}}
+				return reflect.DeepEqual(v, zero)
+			}
+			return reflect.DeepEqual(v, w)
+		}
+
+		r, want := New(3), ring.New(3)
+		for _, op := range ops {
+			n := int(op >> 3)
+			switch op & 7 {
+			case 0:
+				v := value()
+				r.Value, want.Value = v, v
+			case 1:
+				r, want = r.Next(), want.Next()
+			case 2:
+				r, want = r.Prev(), want.Prev()
+			case 3:
+				r, want = r.Move(n-16), want.Move(n-16)
+			case 4:
+				// link a new ring of 1 to 4 random elements.
+				s, ws := New(n%4+1), ring.New(n%4+1)
+				for i := 0; i <= n%4; i++ {
+					v := value()
+					s.Value, ws.Value = v, v
+					s, ws = s.Next(), ws.Next()
+				}
+				r.Link(s)
+				want.Link(ws)
+			default:
+				removed, wremoved := r.Unlink(n), want.Unlink(n)
+				if removed.Len() != wremoved.Len() {
+					t.Fatalf("Unlink(%d) removed %d elements; ring.Ring removed %d", n, removed.Len(), wremoved.Len())
+				}
+			}
+
+			if r.Len() != want.Len() {
+				t.Fatalf("Len() = %d; ring.Ring has %d elements", r.Len(), want.Len())
+			}
+			var got []{{.Type}}
+			r.Do(func(v {{.Type}}) {
+				got = append(got, v)
+			})
+			i := 0
+			want.Do(func(w interface{}) {
+				if i < len(got) && !equal(got[i], w) {
+					t.Fatalf("element %d = %v; ring.Ring has %v", i, got[i], w)
+				}
+				i++
+			})
+			if i != len(got) {
+				t.Fatalf("Do visited %d elements; ring.Ring visited %d", len(got), i)
+			}
+		}
+	})
+}
+`
+
+// Fuzz returns the fuzz test comparing the instantiation with ring.Ring.
+func (g *Generator) Fuzz() string {
+	return generator.RenderTest(fuzz, map[string]string{
+		"Ring": strings.Title(g.name) + "Ring",
+		"Type": g.typ,
+	})
+}
@@ -0,0 +1,64 @@
+package containerring
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// test fills a ring with random values and walks it with Do and Move.
+const test = `import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func Test{{.Ring}}(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	value := {{template "value" .Type}}
+
+	const n = 10
+	r := New(n)
+	var want []{{.Type}}
+	for i := 0; i < n; i++ {
+		v := value()
+		want = append(want, v)
+		r.Value = v
+		r = r.Next()
+	}
+	if r.Len() != n {
+		t.Fatalf("Len() = %d; want %d", r.Len(), n)
+	}
+
+	i := 0
+	r.Do(func(v {{.Type}}) {
+		if !reflect.DeepEqual(v, want[i]) {
+			t.Errorf("Do visited %v at %d; want %v", v, i, want[i])
+		}
+		i++
+	})
+	if i != n {
+		t.Errorf("Do visited %d elements; want %d", i, n)
+	}
+	if v := r.Move(3).Value; !reflect.DeepEqual(v, want[3]) {
+		t.Errorf("Move(3).Value = %v; want %v", v, want[3])
+	}
+	if removed := r.Unlink(3); removed.Len() != 3 || r.Len() != n-3 {
+		t.Errorf("Unlink(3) left rings of %d and %d elements; want 3 and %d", removed.Len(), r.Len(), n-3)
+	}
+}
+`
+
+// Test returns the companion test of the instantiation.
+func (g *Generator) Test() string {
+	return generator.RenderTest(test, map[string]string{
+		"Ring": strings.Title(g.name) + "Ring",
+		"Type": g.typ,
+	})
+}
+
+// UpstreamTests returns the tests of container/ring, which use int values.
+func (g *Generator) UpstreamTests() ([]string, string) {
+	return []string{"src/container/ring/ring_test.go"}, g.typ
+}
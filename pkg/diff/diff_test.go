@@ -0,0 +1,59 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines of the numbers from to to, replacing those
+// in repl.
+func numbered(from, to int, repl map[int]string) string {
+	b := &strings.Builder{}
+	for i := from; i <= to; i++ {
+		if s, ok := repl[i]; ok {
+			b.WriteString(s + "\n")
+			continue
+		}
+		fmt.Fprintf(b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"both empty", "", "", ""},
+		{"insert at start", "b\nc\nd\n", "a\nb\nc\nd\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+a\n b\n c\n d\n"},
+		{"insert at end", "a\nb\nc\n", "a\nb\nc\nd\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n a\n b\n c\n+d\n"},
+		{"delete at start", "a\nb\nc\nd\n", "b\nc\nd\n",
+			"--- a\n+++ b\n@@ -1,4 +1,3 @@\n-a\n b\n c\n d\n"},
+		{"delete at end", "a\nb\nc\nd\n", "a\nb\nc\n",
+			"--- a\n+++ b\n@@ -1,4 +1,3 @@\n a\n b\n c\n-d\n"},
+		{"change in the middle", numbered(1, 9, nil), numbered(1, 9, map[int]string{5: "x"}),
+			"--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n"},
+		{"old without trailing newline", "a\nb", "a\nb\n",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"new without trailing newline", "a\nb\n", "a\nc",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n"},
+		{"empty old", "", "a\nb\n",
+			"--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"empty new", "a\nb\n", "",
+			"--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		// 2*context unchanged lines between changes share a hunk.
+		{"close hunks", numbered(1, 10, nil), numbered(1, 10, map[int]string{1: "x", 8: "y"}),
+			"--- a\n+++ b\n@@ -1,10 +1,10 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n 9\n 10\n"},
+		{"far hunks", numbered(1, 11, nil), numbered(1, 11, map[int]string{1: "x", 9: "y"}),
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -6,6 +6,6 @@\n 6\n 7\n 8\n-9\n+y\n 10\n 11\n"},
+	} {
+		if got := Unified("a", "b", []byte(c.a), []byte(c.b)); got != c.want {
+			t.Errorf("%s: Unified(%q, %q) =\n%s\nwant\n%s", c.name, c.a, c.b, got, c.want)
+		}
+	}
+}
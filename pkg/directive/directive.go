@@ -0,0 +1,240 @@
+// Package directive finds the //go:generate directives invoking
+// go-generate in a tree of Go packages.
+package directive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Command is the name of the go-generate binary.
+const Command = "go-generate"
+
+// Directive is a //go:generate line invoking go-generate.
+type Directive struct {
+	Pos     token.Position // location of the directive.
+	Dir     string         // absolute directory go generate runs the command in.
+	Package string         // package name of the file holding the directive.
+	Args    []string       // arguments following the command name.
+}
+
+// Find returns the directives of the packages matched by patterns, which
+// are directories optionally followed by "/..." to include every package
+// below them.
+func Find(patterns ...string) ([]Directive, error) {
+	var dirs []string
+	for _, pattern := range patterns {
+		matched, err := Match(pattern)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, matched...)
+	}
+
+	var directives []Directive
+	for _, dir := range dirs {
+		pkg, err := build.ImportDir(dir, build.ImportComment)
+		if _, ok := err.(*build.NoGoError); ok {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files := append(append(append([]string{}, pkg.GoFiles...), pkg.CgoFiles...), pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+		for _, name := range files {
+			found, err := Scan(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			directives = append(directives, found...)
+		}
+	}
+	return directives, nil
+}
+
+// Match expands a single pattern into the directories it names.
+func Match(pattern string) ([]string, error) {
+	root, recursive := pattern, false
+	if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+		root, recursive = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/"), true
+		if root == "" {
+			root = "."
+		}
+	}
+	root = filepath.Clean(root)
+	if !recursive {
+		return []string{root}, nil
+	}
+
+	var dirs []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if p != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	return dirs, err
+}
+
+// Scan returns the go-generate directives of a single file.
+func Scan(filename string) ([]Directive, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+	pkgName := f.Name.Name
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	var directives []Directive
+	s := bufio.NewScanner(bytes.NewReader(src))
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if !strings.HasPrefix(text, "//go:generate ") && !strings.HasPrefix(text, "//go:generate\t") {
+			continue
+		}
+		pos := token.Position{Filename: filename, Line: line, Column: 1}
+		words, err := split(text[len("//go:generate "):], pos, pkgName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pos, err)
+		}
+		args, ok := arguments(words)
+		if !ok {
+			continue
+		}
+		directives = append(directives, Directive{
+			Pos:     pos,
+			Dir:     dir,
+			Package: pkgName,
+			Args:    args,
+		})
+	}
+	return directives, s.Err()
+}
+
+// arguments returns the go-generate arguments of a directive, accepting
+// both the installed binary and "go run" invocations.
+func arguments(words []string) ([]string, bool) {
+	if len(words) == 0 {
+		return nil, false
+	}
+	if isCommand(words[0]) {
+		return words[1:], true
+	}
+	if len(words) >= 3 && words[0] == "go" && words[1] == "run" {
+		for i := 2; i < len(words); i++ {
+			if !strings.HasPrefix(words[i], "-") {
+				return words[i+1:], isCommand(words[i])
+			}
+			if flag := strings.TrimLeft(words[i], "-"); valueFlags[flag] {
+				i++ // the value of the flag.
+			}
+		}
+	}
+	return nil, false
+}
+
+// valueFlags are the flags of go run taking a value, when it is not given
+// after an equal sign.
+var valueFlags = map[string]bool{
+	"C": true, "p": true, "asmflags": true, "buildmode": true, "compiler": true,
+	"exec": true, "gccgoflags": true, "gcflags": true, "installsuffix": true,
+	"ldflags": true, "mod": true, "modfile": true, "overlay": true, "pgo": true,
+	"pkgdir": true, "tags": true, "toolexec": true,
+}
+
+func isCommand(word string) bool {
+	if i := strings.LastIndex(word, "@"); i >= 0 {
+		word = word[:i]
+	}
+	return path.Base(filepath.ToSlash(word)) == Command
+}
+
+// split breaks a directive into words the way go generate does: words are
+// separated by spaces, double quoted strings are unquoted and environment
+// variables are expanded.
+func split(line string, pos token.Position, pkg string) ([]string, error) {
+	var words []string
+	line = strings.TrimSpace(line)
+	for line != "" {
+		if line[0] == '"' {
+			end := 1
+			for ; end < len(line); end++ {
+				if line[end] == '\\' {
+					end++
+					continue
+				}
+				if line[end] == '"' {
+					break
+				}
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated quoted string in //go:generate line")
+			}
+			word, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad quoted string in //go:generate line: %s", err)
+			}
+			words = append(words, expand(word, pos, pkg))
+			line = strings.TrimLeft(line[end+1:], " \t")
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		words = append(words, expand(line[:end], pos, pkg))
+		line = strings.TrimLeft(line[end:], " \t")
+	}
+	return words, nil
+}
+
+// expand replaces environment variables the way go generate does.
+func expand(word string, pos token.Position, pkg string) string {
+	return os.Expand(word, func(name string) string {
+		switch name {
+		case "GOFILE":
+			return filepath.Base(pos.Filename)
+		case "GOLINE":
+			return strconv.Itoa(pos.Line)
+		case "GOPACKAGE":
+			return pkg
+		case "GOARCH":
+			return runtime.GOARCH
+		case "GOOS":
+			return runtime.GOOS
+		case "GOROOT":
+			return runtime.GOROOT()
+		case "DOLLAR":
+			return "$"
+		}
+		return os.Getenv(name)
+	})
+}
@@ -0,0 +1,113 @@
+package directive
+
+import (
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	t.Setenv("GO_GENERATE_TEST", "env")
+	pos := token.Position{Filename: filepath.Join("dir", "file.go"), Line: 12}
+	for _, c := range []struct {
+		line string
+		want []string
+	}{
+		{"go-generate -name X map[string]int", []string{"go-generate", "-name", "X", "map[string]int"}},
+		{"  go-generate \t-name\t\tX  ", []string{"go-generate", "-name", "X"}},
+		{`go-generate "map[string]*T" x`, []string{"go-generate", "map[string]*T", "x"}},
+		{`"a b"`, []string{"a b"}},
+		{`"a b""c"`, []string{"a b", "c"}},
+		{`"a\"b" "\tc\\"`, []string{`a"b`, "\tc\\"}},
+		{`a"b"`, []string{`a"b"`}}, // quotes only start words.
+		{"$GOFILE:$GOLINE $GOPACKAGE", []string{"file.go:12", "pkg"}},
+		{`"${GOFILE}" $GOARCH/$GOOS`, []string{"file.go", runtime.GOARCH + "/" + runtime.GOOS}},
+		{"$DOLLAR$DOLLAR{GOFILE} $DOLLARGOFILE", []string{"$${GOFILE}", ""}},
+		{"$GO_GENERATE_TEST ${GO_GENERATE_TEST}x $GO_GENERATE_UNSET.", []string{"env", "envx", "."}},
+		{"", nil},
+	} {
+		got, err := split(c.line, pos, "pkg")
+		if err != nil {
+			t.Errorf("split(%q): %v", c.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("split(%q) = %q; want %q", c.line, got, c.want)
+		}
+	}
+	for _, line := range []string{`"a`, `a "b\"`, `"\q"`} {
+		if got, err := split(line, pos, "pkg"); err == nil {
+			t.Errorf("split(%q) = %q; want an error", line, got)
+		}
+	}
+}
+
+func TestArguments(t *testing.T) {
+	for _, c := range []struct {
+		words string
+		want  []string
+		ok    bool
+	}{
+		{"go-generate -name X int", []string{"-name", "X", "int"}, true},
+		{"go-generate", []string{}, true},
+		{"/go/bin/go-generate int", []string{"int"}, true},
+		{"go run github.com/joesonw/go-generate int", []string{"int"}, true},
+		{"go run github.com/joesonw/go-generate@v1.2.3 -name X int", []string{"-name", "X", "int"}, true},
+		{"go run github.com/joesonw/go-generate@latest", []string{}, true},
+		{"go run ./cmd/go-generate int", []string{"int"}, true},
+		{"go run -mod=mod github.com/joesonw/go-generate int", []string{"int"}, true},
+		{"go run -tags x,y -mod mod -race github.com/joesonw/go-generate int", []string{"int"}, true},
+		{"go run --tags x github.com/joesonw/go-generate int", []string{"int"}, true},
+		{"go run golang.org/x/tools/cmd/stringer@latest -type T", nil, false},
+		{"go run -tags go-generate stringer", nil, false},
+		{"go run -mod=mod", nil, false},
+		{"go run", nil, false},
+		{"go build go-generate", nil, false},
+		{"stringer -type T", nil, false},
+		{"go-generate-other", nil, false},
+		{"", nil, false},
+	} {
+		got, ok := arguments(strings.Fields(c.words))
+		if ok != c.ok || ok && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("arguments(%q) = %q, %v; want %q, %v", c.words, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "p.go")
+	src := `// Package p has directives.
+package p
+
+//go:generate go-generate -generator sync/map -name M map[string]int
+//go:generate stringer -type T
+//go:generate	go run github.com/joesonw/go-generate@v1.0.0 -out $GOPACKAGE.go sync/map:M:map[int]int
+// go:generate go-generate -generator sync/map -name N map[string]int
+`
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Scan(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Directive{
+		{Pos: token.Position{Filename: filename, Line: 4, Column: 1}, Dir: dir, Package: "p", Args: []string{"-generator", "sync/map", "-name", "M", "map[string]int"}},
+		{Pos: token.Position{Filename: filename, Line: 6, Column: 1}, Dir: dir, Package: "p", Args: []string{"-out", "p.go", "sync/map:M:map[int]int"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan = %+v; want %+v", got, want)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte("package p\n\n//go:generate go-generate \"x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Scan(filename); err == nil || !strings.Contains(err.Error(), "p.go:3:1") {
+		t.Errorf("scan of an unterminated string = %v; want an error at p.go:3:1", err)
+	}
+}
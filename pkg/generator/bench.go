@@ -0,0 +1,34 @@
+package generator
+
+import "strings"
+
+// Benchmarker is implemented by generators writing benchmarks of their
+// instantiations. Bench returns the imports and declarations of a test file
+// of the package of the generated file, benchmarking the hot paths of an
+// instantiation and of the original type with the same values, as the
+// sub-benchmarks of a benchmark named after the instantiation.
+type Benchmarker interface {
+	Bench() string
+}
+
+// benchmarks is the companion file of the benchmarks.
+var benchmarks = companion{"benchmarks", "benchmark", func(impl Implementation) (string, bool) {
+	b, ok := impl.(Benchmarker)
+	if !ok {
+		return "", false
+	}
+	return b.Bench(), true
+}}
+
+// BundleBenchmarks returns the benchmarks of the file generated by Bundle
+// for reqs, to be saved at filename. Every generator of reqs must write
+// benchmarks.
+func BundleBenchmarks(filename string, reqs ...Request) ([]byte, error) {
+	return bundleCompanion(benchmarks, filename, reqs...)
+}
+
+// BenchFile returns the path of the benchmarks of the generated file at
+// filename.
+func BenchFile(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_bench_test.go"
+}
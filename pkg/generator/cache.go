@@ -0,0 +1,158 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sync"
+)
+
+// Cache shares parsed upstream sources between generators, so that many
+// instantiations of the same generator only read and parse the file once.
+// It is safe for concurrent use.
+type Cache struct {
+	fset *token.FileSet
+
+	mu      sync.Mutex
+	files   map[string]*cachedFile
+	targets map[string]*cachedTarget
+}
+
+type cachedFile struct {
+	once sync.Once
+	file *ast.File
+	hash string
+	err  error
+}
+
+type cachedTarget struct {
+	once   sync.Once
+	target *Target
+	err    error
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{
+		fset:    token.NewFileSet(),
+		files:   map[string]*cachedFile{},
+		targets: map[string]*cachedTarget{},
+	}
+}
+
+// target returns the loaded target package in dir. A nil cache loads the
+// package every time.
+func (c *Cache) target(dir string) (*Target, error) {
+	if c == nil {
+		return LoadTarget(dir)
+	}
+	dir = absPath(dir)
+	c.mu.Lock()
+	ct, ok := c.targets[dir]
+	if !ok {
+		ct = &cachedTarget{}
+		c.targets[dir] = ct
+	}
+	c.mu.Unlock()
+	ct.once.Do(func() { ct.target, ct.err = LoadTarget(dir) })
+	return ct.target, ct.err
+}
+
+// parse returns a private copy of the parsed source file along with its
+// content hash. Mutations are destructive, so every caller receives its
+// own deep copy of the AST.
+func (c *Cache) parse(src Source) (*ast.File, string, error) {
+	path := src.Path
+	c.mu.Lock()
+	cf, ok := c.files[path]
+	if !ok {
+		cf = &cachedFile{}
+		c.files[path] = cf
+	}
+	c.mu.Unlock()
+
+	cf.once.Do(func() {
+		b, err := src.Read()
+		if err != nil {
+			cf.err = &Error{Kind: SourceNotFound, Msg: fmt.Sprintf("read %q file", path), Err: err}
+			return
+		}
+		cf.hash = hashSource(b)
+		cf.file, err = parser.ParseFile(c.fset, path, b, parser.ParseComments)
+		if err != nil {
+			cf.err = &Error{Kind: ShapeChanged, Msg: fmt.Sprintf("parse %q file", path), Err: err}
+		}
+	})
+	if cf.err != nil {
+		return nil, "", cf.err
+	}
+	return cloneAST(cf.file), cf.hash, nil
+}
+
+type cloneKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// cloneAST deep copies f, keeping the identity of shared nodes such as
+// objects, scopes and comment groups.
+func cloneAST(f *ast.File) *ast.File {
+	seen := map[cloneKey]reflect.Value{}
+	return deepCopy(reflect.ValueOf(f), seen).Interface().(*ast.File)
+}
+
+func deepCopy(v reflect.Value, seen map[cloneKey]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		key := cloneKey{v.Type(), v.Pointer()}
+		if c, ok := seen[key]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[key] = c
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value(), seen))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i), seen))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
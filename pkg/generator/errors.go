@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Kind classifies what went wrong while generating.
+type Kind int
+
+const (
+	Internal       Kind = iota // a bug in go-generate.
+	BadArgument                // malformed flags, names or type expressions.
+	SourceNotFound             // the upstream source can not be found or read.
+	ShapeChanged               // the upstream source no longer looks as expected.
+	WriteFailed                // the generated file can not be written.
+)
+
+var kindNames = [...]string{
+	Internal:       "internal error",
+	BadArgument:    "bad argument",
+	SourceNotFound: "upstream source not found",
+	ShapeChanged:   "upstream shape changed",
+	WriteFailed:    "write failed",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// ExitCode returns the process exit code used for errors of kind k.
+func (k Kind) ExitCode() int {
+	switch k {
+	case BadArgument:
+		return 2
+	case SourceNotFound:
+		return 3
+	case ShapeChanged:
+		return 4
+	case WriteFailed:
+		return 5
+	default:
+		return 1
+	}
+}
+
+// Error is the error returned by generators.
+type Error struct {
+	Kind Kind
+	Pos  token.Position // location of the problem, if known.
+	Msg  string
+	Err  error // underlying error, if any.
+}
+
+// Errorf returns an error of the given kind.
+func Errorf(kind Kind, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Error formats the error as a single line diagnostic.
+func (e *Error) Error() string {
+	b := &strings.Builder{}
+	if e.Pos.IsValid() || e.Pos.Filename != "" {
+		b.WriteString(e.Pos.String())
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(b, "go-generate: %s: %s", e.Kind, e.Msg)
+	if e.Err != nil {
+		fmt.Fprintf(b, ": %s", e.Err)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// At sets the position of the error unless it is already known.
+func (e *Error) At(pos token.Position) *Error {
+	if !e.Pos.IsValid() && e.Pos.Filename == "" {
+		e.Pos = pos
+	}
+	return e
+}
+
+// KindOf returns the kind of err, or Internal if err is not an *Error.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Internal
+}
+
+// ExitCode returns the process exit code for err.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return KindOf(err).ExitCode()
+}
@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"go/token"
+	"sort"
+)
+
+// Handler is an upstream declaration rewritten by a generator.
+type Handler struct {
+	Kind string         // "func", "type" or "value".
+	Name string         // name of the declaration.
+	Pos  token.Position // location in the upstream source, invalid if missing.
+}
+
+// Explanation describes how a generator rewrites its upstream source.
+type Explanation struct {
+	Source   Source
+	Handlers []Handler         // in source order, followed by the missing ones.
+	Renames  map[string]string // upstream identifier => generated identifier.
+	// Unhandled are the declarations without a handler that mention the
+	// empty interface, which is left in the generated code.
+	Unhandled []Handler
+}
+
+// Explain runs the request and reports the upstream declarations it
+// rewrites and the identifiers it renames. Type arguments are neither
+// checked nor imported.
+func (r Request) Explain() (*Explanation, error) {
+	factory, ok := Lookup(r.Generator)
+	if !ok {
+		return nil, unknownFactory(r.Generator)
+	}
+	g, err := factory.New(r.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Mutate(); err != nil {
+		return nil, err
+	}
+
+	e := &Explanation{Source: g.source, Handlers: g.applied, Renames: g.renames, Unhandled: g.unhandledIface}
+	e.Handlers = append(e.Handlers, g.Missing()...)
+	return e, nil
+}
+
+// Missing returns the handlers whose declaration was not found in the
+// upstream source, sorted by name, leaving out the optional ones. It is
+// only meaningful after Mutate.
+func (g *Generator) Missing() []Handler {
+	optional := map[string]bool{}
+	if o, ok := g.impl.(Optional); ok {
+		for _, name := range o.Optional() {
+			optional[name] = true
+		}
+	}
+	var missing []Handler
+	for name := range g.funcs {
+		if !optional[name] {
+			missing = append(missing, Handler{Kind: "func", Name: name})
+		}
+	}
+	for name := range g.types {
+		if !optional[name] {
+			missing = append(missing, Handler{Kind: "type", Name: name})
+		}
+	}
+	for name := range g.values {
+		if !optional[name] {
+			missing = append(missing, Handler{Kind: "value", Name: name})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+	return missing
+}
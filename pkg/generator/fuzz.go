@@ -0,0 +1,48 @@
+package generator
+
+import "strings"
+
+// Fuzzer is implemented by generators writing differential fuzz tests of
+// their instantiations. Fuzz returns the imports and declarations of a test
+// file of the package of the generated file, with fuzz targets applying
+// the same operations to an instantiation and to the original type, and
+// failing when they observe different results.
+type Fuzzer interface {
+	Fuzz() string
+}
+
+// fuzzTests is the companion file of the fuzz targets.
+var fuzzTests = companion{"fuzz tests", "fuzz test", func(impl Implementation) (string, bool) {
+	f, ok := impl.(Fuzzer)
+	if !ok {
+		return "", false
+	}
+	return f.Fuzz(), true
+}}
+
+// BundleFuzzTests returns the fuzz tests of the file generated by Bundle
+// for reqs, to be saved at filename. Every generator of reqs must write
+// fuzz tests.
+func BundleFuzzTests(filename string, reqs ...Request) ([]byte, error) {
+	return bundleCompanion(fuzzTests, filename, reqs...)
+}
+
+// Declares reports whether the mutated file declares name, Type.Method for
+// methods. Fuzz tests use it to skip operations missing from older
+// upstream versions.
+func (g *Generator) Declares(name string) bool {
+	for _, d := range g.file.Decls {
+		for _, n := range declNames(d) {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FuzzTestFile returns the path of the fuzz tests of the generated file at
+// filename.
+func FuzzTestFile(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_fuzz_test.go"
+}
@@ -2,30 +2,52 @@ package generator
 
 import (
 	"bytes"
+	"fmt"
 	"go/ast"
 	"go/format"
 	"go/parser"
 	"go/token"
 	"io"
-	"io/ioutil"
+	pathpkg "path"
+	"strconv"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
 )
 
+// header marks generated files, see https://golang.org/s/generatedcode.
+const header = "// Code generated by go-generate; DO NOT EDIT.\n"
+
 // Generator generates the typed syncmap object.
 type Generator struct {
 	// flag options.
 	pkg    string // package name.
-	source string // source file needed to be mutated
+	source Source // source file needed to be mutated
+	origin Provenance
 
 	// mutation state and traversal handlers.
-	file *ast.File
-	fset *token.FileSet
+	file  *ast.File
+	fset  *token.FileSet
+	cache *Cache
 
 	impl   Implementation
 	funcs  map[string]func(*ast.FuncDecl)
 	types  map[string]func(*ast.TypeSpec)
 	values map[string]func(*ast.ValueSpec)
+
+	strict bool
+	notes  []string // choices made on behalf of the user, see Note.
+
+	// packages of qualified type arguments and names they may not use.
+	imports  []Import
+	reserved []string
+
+	// what Mutate did, for Explain.
+	applied        []Handler
+	renames        map[string]string
+	unhandledIface []Handler
+	decls          map[string]Handler // upstream declarations by declNames.
 }
 
 type Implementation interface {
@@ -35,8 +57,15 @@ type Implementation interface {
 	Mutate() error
 }
 
+// Optional is implemented by generators with handlers of declarations
+// that only some upstream versions have, such as methods added by a Go
+// release. Their absence is not reported as drift.
+type Optional interface {
+	Optional() []string
+}
+
 // NewGenerator returns a new generator.
-func New(pkg, source string, impl Implementation) (g *Generator, err error) {
+func New(pkg string, source Source, impl Implementation) (g *Generator, err error) {
 	defer Catch(&err)
 	g = &Generator{
 		fset:   token.NewFileSet(),
@@ -44,6 +73,7 @@ func New(pkg, source string, impl Implementation) (g *Generator, err error) {
 		impl:   impl,
 		source: source,
 	}
+	g.origin.Source = source
 	g.funcs = impl.Funcs()
 	g.types = impl.Types()
 	g.values = impl.Values()
@@ -52,70 +82,308 @@ func New(pkg, source string, impl Implementation) (g *Generator, err error) {
 }
 
 // Mutate mutates the original AST and brings it to the desired state.
-// It fails if it encounters an unrecognized node in the AST.
+// It fails if it encounters an unrecognized node in the AST, and in strict
+// mode if the upstream source drifted from the handlers.
 func (g *Generator) Mutate() (err error) {
 	defer Catch(&err)
-	path := g.source
-	b, err := ioutil.ReadFile(path)
-	Check(err, "read %q file", path)
-	f, err := parser.ParseFile(g.fset, "", b, parser.ParseComments)
-	Check(err, "parse %q file", path)
+	f := g.parse()
 	f.Name.Name = g.pkg
+	var unhandled []Handler
+	g.decls = map[string]Handler{}
 	for _, d := range f.Decls {
+		g.record(d)
 		switch d := d.(type) {
 		case *ast.FuncDecl:
-			handler, ok := g.funcs[d.Name.Name]
+			name := d.Name.Name
+			handler, ok := g.funcs[name]
 			if !ok {
+				unhandled = g.unhandled(unhandled, "func", name, d)
 				continue
 			}
-			handler(d)
-			delete(g.funcs, d.Name.Name)
+			g.apply("func", name, d, func() { handler(d) })
+			delete(g.funcs, name)
 		case *ast.GenDecl:
 			switch d := d.Specs[0].(type) {
 			case *ast.TypeSpec:
-				handler, ok := g.types[d.Name.Name]
+				name := d.Name.Name
+				handler, ok := g.types[name]
 				if !ok {
+					unhandled = g.unhandled(unhandled, "type", name, d)
 					continue
 				}
-				handler(d)
-				delete(g.types, d.Name.Name)
+				g.apply("type", name, d, func() { handler(d) })
+				delete(g.types, name)
 			case *ast.ValueSpec:
-				handler, ok := g.values[d.Names[0].Name]
+				name := d.Names[0].Name
+				handler, ok := g.values[name]
 				if !ok {
+					unhandled = g.unhandled(unhandled, "value", name, d)
 					continue
 				}
-				handler(d)
-				Expect(len(d.Names) == 1, "mismatch values length: %d", len(d.Names))
-				delete(g.values, d.Names[0].Name)
+				g.apply("value", name, d, func() { handler(d) })
+				ExpectKind(ShapeChanged, len(d.Names) == 1, "mismatch values length: %d", len(d.Names))
+				delete(g.values, name)
 			}
 		default:
-			Expect(false, "unrecognized type: %s", d)
+			panic(Errorf(ShapeChanged, "unrecognized declaration: %T", d).At(g.fset.Position(d.Pos())))
 		}
 	}
-	//Expect(len(g.funcs) == 0, "function was deleted")
-	//Expect(len(g.types) == 0, "type was deleted")
-	//Expect(len(g.values) == 0, "value was deleted")
+	g.unhandledIface = unhandled
+	if g.strict {
+		g.checkDrift()
+	}
 	g.file = f
 	return g.impl.Mutate()
 }
 
+// record remembers the names of the upstream declaration d, before any
+// handler or rename changes them.
+func (g *Generator) record(d ast.Decl) {
+	kind, pos := "func", d.Pos()
+	if gd, ok := d.(*ast.GenDecl); ok {
+		if gd.Tok == token.IMPORT || len(gd.Specs) == 0 {
+			return
+		}
+		// handlers are positioned at the first spec, see Mutate.
+		kind, pos = "value", gd.Specs[0].Pos()
+		if gd.Tok == token.TYPE {
+			kind = "type"
+		}
+	}
+	for _, name := range declNames(d) {
+		g.decls[name] = Handler{Kind: kind, Name: name, Pos: g.fset.Position(pos)}
+	}
+}
+
+// unhandled appends the declaration n without a handler to list if it
+// mentions the empty interface, which then leaks into the generated code.
+func (g *Generator) unhandled(list []Handler, kind, name string, n ast.Node) []Handler {
+	if mentionsIface(n) {
+		list = append(list, Handler{Kind: kind, Name: name, Pos: g.fset.Position(n.Pos())})
+	}
+	return list
+}
+
+// checkDrift fails if handlers are missing upstream or if declarations
+// without a handler mention the empty interface.
+func (g *Generator) checkDrift() {
+	missing := g.Missing()
+	if len(missing) == 0 && len(g.unhandledIface) == 0 {
+		return
+	}
+	var problems []string
+	if len(missing) > 0 {
+		names := make([]string, len(missing))
+		for i, h := range missing {
+			names[i] = h.Kind + " " + h.Name
+		}
+		problems = append(problems, "missing upstream: "+strings.Join(names, ", "))
+	}
+	if len(g.unhandledIface) > 0 {
+		names := make([]string, len(g.unhandledIface))
+		for i, h := range g.unhandledIface {
+			names[i] = fmt.Sprintf("%s %s (%s:%d)", h.Kind, h.Name, pathpkg.Base(h.Pos.Filename), h.Pos.Line)
+		}
+		problems = append(problems, "interface{} or any without a handler in: "+strings.Join(names, ", "))
+	}
+	panic(Errorf(ShapeChanged, "%s drifted from the handlers; %s", g.source, strings.Join(problems, "; ")))
+}
+
+// SetStrict makes Mutate fail when the upstream source drifted from the
+// handlers of the generator.
+func (g *Generator) SetStrict(strict bool) {
+	g.strict = strict
+}
+
+// apply runs the handler of the named declaration. Failures are reported
+// at the position of the declaration, except bad arguments, which belong
+// to the request. A handler panicking on an AST it does not expect is an
+// internal error.
+func (g *Generator) apply(kind, name string, n ast.Node, handler func()) {
+	g.applied = append(g.applied, Handler{Kind: kind, Name: name, Pos: g.fset.Position(n.Pos())})
+	defer func() {
+		if e := recover(); e != nil {
+			gerr, ok := e.(*Error)
+			if !ok {
+				gerr = &Error{Kind: Internal, Msg: fmt.Sprintf("handler of %s %s", kind, name), Err: fmt.Errorf("%v", e)}
+			}
+			if gerr.Kind != BadArgument {
+				gerr.At(g.fset.Position(n.Pos()))
+			}
+			panic(gerr)
+		}
+	}()
+	handler()
+}
+
+// SetCache makes the generator read its upstream source through c,
+// sharing the parsed file with other generators using the same cache.
+func (g *Generator) SetCache(c *Cache) {
+	g.cache = c
+}
+
+func (g *Generator) parse() *ast.File {
+	path := g.source.Path
+	if g.cache != nil {
+		f, hash, err := g.cache.parse(g.source)
+		if err != nil {
+			panic(err)
+		}
+		g.fset = g.cache.fset
+		g.origin.Hash = hash
+		return f
+	}
+	b, err := g.source.Read()
+	CheckKind(SourceNotFound, err, "read %q file", path)
+	f, err := parser.ParseFile(g.fset, path, b, parser.ParseComments)
+	CheckKind(ShapeChanged, err, "parse %q file", path)
+	g.origin.Hash = hashSource(b)
+	return f
+}
+
+// SetOrigin records the instantiation the generator was created for.
+func (g *Generator) SetOrigin(generator string, o Options) {
+	g.origin.Generator = generator
+	g.origin.Name = o.Name
+	g.origin.Type = o.Type
+}
+
+// Note records a choice the generator made on behalf of the user, such as
+// a substituted source, for the caller to report.
+func (g *Generator) Note(format string, args ...interface{}) {
+	g.notes = append(g.notes, fmt.Sprintf(format, args...))
+}
+
+// Notes returns the notes recorded by the generator.
+func (g *Generator) Notes() []string {
+	return g.notes
+}
+
+// Provenance returns where the generated code comes from. The content
+// hash is only known once Mutate has run.
+func (g *Generator) Provenance() Provenance {
+	return g.origin
+}
+
 // Gen dumps the mutated AST to a file in the configured destination.
 func (g *Generator) Generate() (out []byte, err error) {
 	defer Catch(&err)
-	b := bytes.NewBuffer([]byte("// Code generated by go-generate; DO NOT EDIT.\n\n"))
-	err = format.Node(b, g.fset, g.file)
+	body, err := g.format()
 	Check(err, "format mutated code")
+	return append(Header(g.origin), body...), nil
+}
+
+// format prints the mutated AST without the header.
+func (g *Generator) format() ([]byte, error) {
+	b := &bytes.Buffer{}
+	err := format.Node(b, g.fset, g.file)
 	return b.Bytes(), err
 }
 
+// FixImports adds missing and removes unused imports of src, resolving
+// packages as if it was the file at filename.
+func FixImports(filename string, src []byte) ([]byte, error) {
+	b, err := imports.Process(filename, src, nil)
+	if err != nil {
+		return nil, &Error{Kind: Internal, Msg: fmt.Sprintf("fix imports of %s", filename), Err: err}
+	}
+	return b, nil
+}
+
 func (g *Generator) FormatNode(dst io.Writer, node interface{}) error {
 	return format.Node(dst, g.fset, node)
 }
 
 func (g *Generator) Rename(names map[string]string) {
+	if g.renames == nil {
+		g.renames = map[string]string{}
+	}
+	for old, name := range names {
+		g.renames[old] = name
+	}
 	Rename(g.file, names)
 }
 
 func (g *Generator) AddImport(path string) {
 	astutil.AddImport(g.fset, g.file, path)
 }
+
+// AddNamedImport adds the import of path bound to name.
+func (g *Generator) AddNamedImport(name, path string) {
+	astutil.AddNamedImport(g.fset, g.file, name, path)
+}
+
+// placeholderPrefix marks the qualifiers of type arguments while the
+// upstream source is mutated, so that they can not be confused with the
+// package names used by the source itself.
+const placeholderPrefix = "goGenerateQualifier_"
+
+// placeholders maps the qualifiers of imports to their placeholders.
+func placeholders(imports []Import) map[string]string {
+	m := make(map[string]string, len(imports))
+	for _, imp := range imports {
+		m[imp.Name] = placeholderPrefix + imp.Name
+	}
+	return m
+}
+
+// qualify replaces the placeholder qualifiers of the mutated file with
+// imports of their packages, see qualifyFile. The imports are kept for
+// the companion test.
+func (g *Generator) qualify(imports []Import, reserved []string) {
+	g.imports, g.reserved = imports, reserved
+	qualifyFile(g.fset, g.file, imports, reserved)
+}
+
+// qualifyFile replaces the placeholder qualifiers of f with imports of
+// their packages. A qualifier is aliased when its name is already used by the
+// file or is one of reserved.
+func qualifyFile(fset *token.FileSet, f *ast.File, imports []Import, reserved []string) {
+	if len(imports) == 0 {
+		return
+	}
+	bound := map[string]string{} // import name => path.
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := pathpkg.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		bound[name] = path
+	}
+	taken := map[string]bool{}
+	for _, name := range reserved {
+		taken[name] = true
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id != f.Name && !strings.HasPrefix(id.Name, placeholderPrefix) {
+			taken[id.Name] = true
+		}
+		return true
+	})
+
+	names := map[string]string{}
+	for _, imp := range imports {
+		alias := imp.Name
+		if bound[alias] != imp.Path {
+			for n := 2; taken[alias] || bound[alias] != ""; n++ {
+				alias = imp.Name + strconv.Itoa(n)
+			}
+			if alias == imp.Package {
+				astutil.AddImport(fset, f, imp.Path)
+			} else {
+				astutil.AddNamedImport(fset, f, alias, imp.Path)
+			}
+			bound[alias] = imp.Path
+		}
+		names[placeholderPrefix+imp.Name] = alias
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && names[id.Name] != "" {
+				id.Name = names[id.Name]
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bundle generates every request into a single file saved at filename.
+// Imports are merged, identical helper declarations are emitted once and
+// conflicting declarations are reported as errors.
+func Bundle(filename string, reqs ...Request) ([]byte, error) {
+	if len(reqs) == 1 {
+		reqs[0].Filename = filename
+		return reqs[0].Generate()
+	}
+	files := make([][]byte, 0, len(reqs))
+	labels := make([]string, 0, len(reqs))
+	provs := make([]Provenance, 0, len(reqs))
+	for _, r := range reqs {
+		r.Filename = filename
+		g, err := r.mutate()
+		if err != nil {
+			return nil, err
+		}
+		b, err := g.format()
+		if err != nil {
+			return nil, &Error{Kind: Internal, Msg: "format mutated code", Err: err}
+		}
+		files = append(files, b)
+		labels = append(labels, fmt.Sprintf("%s %s", r.Generator, r.Name))
+		provs = append(provs, g.Provenance())
+	}
+	b, err := merge(files, labels)
+	if err != nil {
+		return nil, err
+	}
+	return finish(filename, append(Header(provs...), b...))
+}
+
+// merge combines generated files of the same package into one. labels
+// name the instantiation each file comes from, for error messages.
+func merge(files [][]byte, labels []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	var (
+		pkg      string
+		licenses []string
+		imports  = map[string]string{} // import spec => path, for sorting.
+		decls    []string
+		owners   = map[string]int{} // declared name => index of the file.
+		texts    = map[string]string{}
+	)
+	for i, src := range files {
+		f, err := parser.ParseFile(fset, labels[i], src, parser.ParseComments)
+		if err != nil {
+			return nil, &Error{Kind: Internal, Msg: fmt.Sprintf("parse generated %s", labels[i]), Err: err}
+		}
+		if pkg == "" {
+			pkg = f.Name.Name
+		} else if pkg != f.Name.Name {
+			return nil, Errorf(BadArgument, "%s is generated in package %s, expected %s", labels[i], f.Name.Name, pkg)
+		}
+		tf := fset.File(f.Pos())
+		text := func(from, to token.Pos) string {
+			return string(src[tf.Offset(from):tf.Offset(to)])
+		}
+
+		for _, c := range f.Comments {
+			if c.Pos() >= f.Package || c == f.Doc {
+				continue
+			}
+			if l := strings.TrimSpace(text(c.Pos(), c.End())); !contains(licenses, l) {
+				licenses = append(licenses, l)
+			}
+		}
+
+		prev := f.Name.End()
+		for _, d := range f.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				for _, spec := range gd.Specs {
+					spec := spec.(*ast.ImportSpec)
+					path, _ := strconv.Unquote(spec.Path.Value)
+					imports[text(spec.Pos(), spec.End())] = path
+				}
+				prev = d.End()
+				continue
+			}
+			chunk := strings.TrimSpace(text(prev, d.End()))
+			prev = d.End()
+
+			duplicate := false
+			for _, name := range declNames(d) {
+				owner, ok := owners[name]
+				if !ok {
+					continue
+				}
+				if texts[name] != chunk {
+					return nil, Errorf(BadArgument, "%s and %s both declare %s", labels[owner], labels[i], name)
+				}
+				duplicate = true
+			}
+			if duplicate {
+				continue
+			}
+			for _, name := range declNames(d) {
+				owners[name] = i
+				texts[name] = chunk
+			}
+			decls = append(decls, chunk)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	for _, l := range licenses {
+		b.WriteString(l)
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+	if len(imports) > 0 {
+		specs := make([]string, 0, len(imports))
+		for spec := range imports {
+			specs = append(specs, spec)
+		}
+		sort.Slice(specs, func(i, j int) bool { return imports[specs[i]] < imports[specs[j]] })
+		fmt.Fprintf(b, "import (\n\t%s\n)\n\n", strings.Join(specs, "\n\t"))
+	}
+	b.WriteString(strings.Join(decls, "\n\n"))
+	b.WriteString("\n")
+
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, &Error{Kind: Internal, Msg: "format merged code", Err: err}
+	}
+	return out, nil
+}
+
+// declNames returns the top level names declared by d. Methods are
+// qualified by the name of their receiver type.
+func declNames(d ast.Decl) []string {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil || len(d.Recv.List) == 0 {
+			return []string{d.Name.Name}
+		}
+		return []string{recvName(d.Recv.List[0].Type) + "." + d.Name.Name}
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range spec.Names {
+					if n.Name != "_" {
+						names = append(names, n.Name)
+					}
+				}
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func recvName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.StarExpr:
+		return recvName(e.X)
+	case *ast.IndexExpr:
+		return recvName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package generator
+
+import (
+	"go/build"
+)
+
+// PackageName returns the name of the Go package in dir, ignoring test
+// files and files excluded by build constraints.
+func PackageName(dir string) (string, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		switch err.(type) {
+		case *build.NoGoError:
+			return "", Errorf(BadArgument, "can not determine the package name: no Go files in %s, use -package", dir)
+		case *build.MultiplePackageError:
+			return "", &Error{Kind: BadArgument, Msg: "can not determine the package name, use -package", Err: err}
+		}
+		if pkg == nil || pkg.Name == "" {
+			return "", &Error{Kind: BadArgument, Msg: "can not determine the package name, use -package", Err: err}
+		}
+	}
+	return pkg.Name, nil
+}
@@ -0,0 +1,461 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	pathpkg "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// UpstreamTester is implemented by generators which can port the tests of
+// their upstream source to the generated code.
+type UpstreamTester interface {
+	// UpstreamTests returns the upstream test files, slash separated
+	// paths within the module of the source, and the type replacing the
+	// empty interface in them.
+	UpstreamTests() (files []string, typ string)
+}
+
+// UpstreamTestMutator is implemented by upstream testers giving other
+// types than the one of UpstreamTests to some empty interfaces of the
+// upstream tests, such as the keys of maps. MutateUpstreamTest is called
+// on every parsed test file before the remaining empty interfaces are
+// replaced, and returns the type expressions it used.
+type UpstreamTestMutator interface {
+	MutateUpstreamTest(f *ast.File) []string
+}
+
+// UpstreamTestFile returns the path of the ported upstream tests of the
+// generated file at filename.
+func UpstreamTestFile(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_upstream_test.go"
+}
+
+// PortTests returns the upstream tests of reqs ported to src, the file
+// generated by Bundle for reqs to be saved at filename. The tests go
+// through the renames of the generators and get the type arguments in
+// place of the empty interface. Upstream tests often use values of
+// several types: declarations which do not compile with the type
+// arguments are left out and listed at the end of the file. If no test of
+// a request is left, the file is returned along with a BadArgument error
+// naming the request, to be reported as a warning, unless the request is
+// Strict, in which case PortTests fails.
+func PortTests(filename string, src []byte, reqs ...Request) ([]byte, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	files := make([][]byte, 0, len(reqs))
+	labels := make([]string, 0, len(reqs))
+	provs := make([]Provenance, 0, len(reqs))
+	for _, r := range reqs {
+		r.Filename = filename
+		g, err := r.mutate()
+		if err != nil {
+			return nil, err
+		}
+		b, ok, err := g.portTests()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, Errorf(BadArgument, "generator %s does not port upstream tests", r.Generator).At(r.Pos)
+		}
+		files = append(files, b)
+		labels = append(labels, fmt.Sprintf("upstream tests of %s %s", r.Generator, r.Name))
+		provs = append(provs, g.Provenance())
+	}
+	body, err := merge(files, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := reqs[0].Cache.target(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	testname := UpstreamTestFile(filename)
+	ported, dropped, err := prune(target, filename, src, testname, body)
+	if err != nil {
+		return nil, err
+	}
+	var unported error
+	for _, r := range reqs {
+		if hasTest(ported, "Test"+strings.Title(r.Name)+"_") {
+			continue
+		}
+		err := Errorf(BadArgument, "no upstream test of %s %s compiles with the type arguments %s", r.Generator, r.Name, r.Type).At(r.Pos)
+		if r.Strict {
+			return nil, err
+		}
+		if unported == nil {
+			unported = err
+		}
+	}
+	if len(dropped) > 0 {
+		ported = append(ported, "\n// Upstream declarations not ported, they do not compile with the type\n// arguments:\n//\n"...)
+		for _, d := range dropped {
+			ported = append(ported, "//\t"+d+"\n"...)
+		}
+	}
+	b, err := finish(testname, append(Header(provs...), ported...))
+	if err != nil {
+		return nil, err
+	}
+	return b, unported
+}
+
+// hasTest reports whether the test file src declares a function whose name
+// starts with prefix.
+func hasTest(src []byte, prefix string) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return false
+	}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && strings.HasPrefix(fd.Name.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// prune type checks test, the ported tests to be saved at testname, with
+// src, the generated file at filename, and removes the declarations of
+// test which do not compile until the rest does. It returns the pruned
+// tests and a line for each removed declaration, with its first error.
+func prune(target *Target, filename string, src []byte, testname string, test []byte) ([]byte, []string, error) {
+	fset := target.Pkg.Fset
+	gen, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, &Error{Kind: Internal, Msg: "parse generated code", Err: err}
+	}
+	var dropped []string
+	for {
+		f, err := parser.ParseFile(fset, testname, test, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, nil, &Error{Kind: Internal, Msg: "parse ported tests", Err: err}
+		}
+		tf := fset.File(f.Pos())
+		reasons := map[ast.Decl]string{}
+		for _, te := range target.typeErrors([]*ast.File{gen, f}, filename, testname) {
+			if fset.File(te.Pos) != tf {
+				continue
+			}
+			d := enclosingDecl(f, te.Pos)
+			if d == nil {
+				return nil, nil, &Error{Kind: Internal, Pos: fset.Position(te.Pos), Msg: "ported tests do not compile", Err: fmt.Errorf("%s", te.Msg)}
+			}
+			if _, ok := reasons[d]; !ok {
+				reasons[d] = te.Msg
+			}
+		}
+		// a type losing a method may no longer implement an interface, such
+		// as quick.Generator, and change the behavior of the tests.
+		for _, d := range f.Decls {
+			if _, ok := reasons[d]; !ok {
+				continue
+			}
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv != nil && len(fd.Recv.List) > 0 {
+				if td := typeDecl(f, recvName(fd.Recv.List[0].Type)); td != nil {
+					if _, ok := reasons[td]; !ok {
+						reasons[td] = "method " + fd.Name.Name + " is not ported"
+					}
+				}
+			}
+		}
+		if len(reasons) == 0 {
+			return test, dropped, nil
+		}
+
+		var decls []ast.Decl
+		for _, d := range f.Decls {
+			reason, ok := reasons[d]
+			if !ok {
+				decls = append(decls, d)
+				continue
+			}
+			names := declNames(d)
+			if len(names) == 0 {
+				names = []string{"_"}
+			}
+			dropped = append(dropped, strings.Join(names, ", ")+": "+strings.Join(strings.Fields(reason), " "))
+			f.Comments = commentsOutside(f.Comments, d)
+		}
+		f.Decls = decls
+		b := &bytes.Buffer{}
+		if err := format.Node(b, fset, f); err != nil {
+			return nil, nil, &Error{Kind: Internal, Msg: "format ported tests", Err: err}
+		}
+		test = b.Bytes()
+	}
+}
+
+// typeDecl returns the declaration of the type name in f.
+func typeDecl(f *ast.File, name string) ast.Decl {
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+			for _, spec := range gd.Specs {
+				if spec.(*ast.TypeSpec).Name.Name == name {
+					return d
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// enclosingDecl returns the top level declaration of f holding pos.
+func enclosingDecl(f *ast.File, pos token.Pos) ast.Decl {
+	for _, d := range f.Decls {
+		if d.Pos() <= pos && pos < d.End() {
+			return d
+		}
+	}
+	return nil
+}
+
+// commentsOutside returns the comments which are not part of d, including
+// its doc comment.
+func commentsOutside(comments []*ast.CommentGroup, d ast.Decl) []*ast.CommentGroup {
+	start := d.Pos()
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	}
+	var kept []*ast.CommentGroup
+	for _, c := range comments {
+		if c.End() <= start || c.Pos() >= d.End() {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// portTests returns the upstream tests of the generator ported to the
+// mutated file, without header. ok is false if the generator does not
+// port its tests.
+func (g *Generator) portTests() (out []byte, ok bool, err error) {
+	defer Catch(&err)
+	tester, ok := g.impl.(UpstreamTester)
+	if !ok {
+		return nil, false, nil
+	}
+	files, typ := tester.UpstreamTests()
+
+	// names of the test files are suffixed like the generated names, so
+	// that several instantiations can be tested in one package.
+	suffix := strings.Title(g.origin.Name)
+	renames := map[string]string{}
+	for old, name := range g.renames {
+		renames[old] = name
+	}
+	sources := make([]Source, len(files))
+	parsed := make([]*ast.File, len(files))
+	for i, file := range files {
+		src := g.source.Sibling(file)
+		b, err := src.Read()
+		CheckKind(SourceNotFound, err, "read upstream test %s", src)
+		f, err := parser.ParseFile(g.fset, src.Path, b, parser.ParseComments)
+		CheckKind(ShapeChanged, err, "parse upstream test %s", src)
+
+		var decls []ast.Decl
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil {
+				if fd.Name.Name == "TestMain" || strings.HasPrefix(fd.Name.Name, "Example") {
+					// a package has a single TestMain, and examples are
+					// checked against the output of upstream values.
+					f.Comments = commentsOutside(f.Comments, d)
+					continue
+				}
+			}
+			decls = append(decls, d)
+			for _, name := range declNames(d) {
+				if !strings.Contains(name, ".") && name != "init" {
+					renames[name] = testName(name, suffix)
+				}
+			}
+		}
+		f.Decls = decls
+		sources[i], parsed[i] = src, f
+	}
+
+	var srcs [][]byte
+	var labels []string
+	for i, f := range parsed {
+		g.unqualify(f)
+		f.Name.Name = g.pkg
+		renameKeepingMethods(f, renames)
+		used := []string{typ}
+		if m, ok := g.impl.(UpstreamTestMutator); ok {
+			used = append(used, m.MutateUpstreamTest(f)...)
+		}
+		replaceEmptyIface(f, typ)
+		for _, t := range used {
+			unwrapAssertions(f, t)
+		}
+		qualifyFile(g.fset, f, g.imports, g.reserved)
+
+		out := &bytes.Buffer{}
+		Check(format.Node(out, g.fset, f), "format upstream test %s", sources[i])
+		srcs = append(srcs, out.Bytes())
+		labels = append(labels, sources[i].String())
+	}
+	b, err := merge(srcs, labels)
+	Check(err, "merge upstream tests")
+	return b, true, nil
+}
+
+// renameKeepingMethods renames the identifiers of f like Rename, except
+// for the names of methods, which are only renamed upstream.
+func renameKeepingMethods(f *ast.File, oldnew map[string]string) {
+	methods := map[*ast.Ident]string{}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv != nil {
+			methods[fd.Name] = fd.Name.Name
+		}
+	}
+	Rename(f, oldnew)
+	for id, name := range methods {
+		id.Name = name
+	}
+}
+
+// testName returns the name of the upstream test declaration name for a
+// generated type suffixed with suffix: TestX becomes TestSuffix_X.
+func testName(name, suffix string) string {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz"} {
+		if rest := strings.TrimPrefix(name, prefix); rest != name && (rest == "" || !isLower(rest)) {
+			return prefix + suffix + "_" + strings.TrimPrefix(rest, "_")
+		}
+	}
+	return name + suffix
+}
+
+func isLower(s string) bool {
+	return s[0] >= 'a' && s[0] <= 'z'
+}
+
+// unqualify turns the identifiers of the upstream package qualified by an
+// external test package into identifiers of the generated file.
+func (g *Generator) unqualify(f *ast.File) {
+	if !strings.HasSuffix(f.Name.Name, "_test") {
+		return
+	}
+	dir := pathpkg.Dir(g.source.File)
+	upstream := g.source.Module + "/" + dir
+	if g.source.Module == "std" {
+		upstream = strings.TrimPrefix(dir, "src/")
+	}
+	var name string
+	for _, spec := range f.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == upstream {
+			name = pathpkg.Base(path)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+		}
+	}
+	if name == "" {
+		return
+	}
+	astutil.Apply(f, func(c *astutil.Cursor) bool {
+		sel, ok := c.Node().(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && x.Name == name && x.Obj == nil {
+			if _, declared := g.decls[sel.Sel.Name]; declared {
+				c.Replace(ast.NewIdent(sel.Sel.Name))
+				return false
+			}
+		}
+		return true
+	}, nil)
+	if !astutil.UsesImport(f, upstream) {
+		astutil.DeleteImport(g.fset, f, upstream)
+	}
+}
+
+// replaceEmptyIface replaces the empty interfaces of n, spelled interface{}
+// or any, with the type expression s.
+func replaceEmptyIface(n ast.Node, s string) {
+	astutil.Apply(n, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.InterfaceType:
+			if n.Methods == nil || len(n.Methods.List) == 0 {
+				c.Replace(Expr(s, n.Interface))
+			}
+		case *ast.Ident:
+			if _, sel := c.Parent().(*ast.SelectorExpr); n.Name == "any" && n.Obj == nil && !sel {
+				c.Replace(Expr(s, n.NamePos))
+			}
+		}
+		return true
+	}, nil)
+}
+
+// unwrapAssertions removes the type assertions to the type expression s,
+// which assert values that used to be interfaces and are now of type s.
+// The second result of a comma-ok assertion becomes true.
+func unwrapAssertions(f *ast.File, s string) {
+	want := types.ExprString(ParseType(s))
+	asserts := func(e ast.Expr) (ast.Expr, bool) {
+		ta, ok := e.(*ast.TypeAssertExpr)
+		if !ok || ta.Type == nil || types.ExprString(ta.Type) != want {
+			return nil, false
+		}
+		return ta.X, true
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if x, ok := commaOk(n.Lhs, n.Rhs, asserts); ok {
+				n.Rhs = []ast.Expr{x, ast.NewIdent("true")}
+			}
+		case *ast.ValueSpec:
+			if x, ok := commaOk(identExprs(n.Names), n.Values, asserts); ok {
+				n.Values = []ast.Expr{x, ast.NewIdent("true")}
+			}
+		}
+		return true
+	})
+	astutil.Apply(f, nil, func(c *astutil.Cursor) bool {
+		if e, ok := c.Node().(ast.Expr); ok {
+			if x, ok := asserts(e); ok {
+				c.Replace(x)
+			}
+		}
+		return true
+	})
+}
+
+// commaOk returns the asserted value of a comma-ok assertion lhs = rhs.
+func commaOk(lhs, rhs []ast.Expr, asserts func(ast.Expr) (ast.Expr, bool)) (ast.Expr, bool) {
+	if len(lhs) != 2 || len(rhs) != 1 {
+		return nil, false
+	}
+	return asserts(rhs[0])
+}
+
+func identExprs(ids []*ast.Ident) []ast.Expr {
+	exprs := make([]ast.Expr, len(ids))
+	for i, id := range ids {
+		exprs[i] = id
+	}
+	return exprs
+}
@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"runtime/debug"
+	"strings"
+)
+
+// Version is the go-generate version recorded in generated files.
+var Version = version()
+
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "devel"
+}
+
+const modulePath = "github.com/joesonw/go-generate"
+
+// Provenance records where an instantiation in a generated file comes from.
+type Provenance struct {
+	Generator string // registered generator name.
+	Name      string // name of the generated type.
+	Type      string // type expression holding the type arguments.
+	Source    Source // upstream file.
+	Hash      string // content hash of the upstream file.
+}
+
+// hashSource returns the content hash recorded for an upstream file.
+func hashSource(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+const (
+	headerVersion  = "// go-generate: "
+	headerInstance = "// instance: "
+	headerSource   = "//   source: "
+	headerHash     = "//   hash: "
+	headerContent  = "// content: "
+)
+
+// Header returns the comment block starting a file generated from provs.
+func Header(provs ...Provenance) []byte {
+	b := bytes.NewBufferString(header)
+	fmt.Fprintf(b, "//\n%s%s\n", headerVersion, Version)
+	for _, p := range provs {
+		if p.Generator != "" {
+			fmt.Fprintf(b, "%s%s %s %s\n", headerInstance, p.Generator, p.Name, p.Type)
+		}
+		fmt.Fprintf(b, "%s%s\n", headerSource, p.Source)
+		fmt.Fprintf(b, "%s%s\n", headerHash, p.Hash)
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// seal records in the header of src, a generated file, the hash of the
+// content following the header, so that edits of the file are noticed.
+func seal(src []byte) []byte {
+	i := bytes.Index(src, []byte("\n\n"))
+	if i < 0 {
+		return src
+	}
+	line := headerContent + hashSource(src[i+2:]) + "\n"
+	out := make([]byte, 0, len(src)+len(line))
+	out = append(out, src[:i+1]...)
+	out = append(out, line...)
+	return append(out, src[i+1:]...)
+}
+
+// sealed reports whether the content of src, a generated file, has the
+// hash recorded in its header.
+func sealed(src []byte) bool {
+	i := bytes.Index(src, []byte("\n\n"))
+	if i < 0 {
+		return false
+	}
+	for _, line := range strings.Split(string(src[:i]), "\n") {
+		if strings.HasPrefix(line, headerContent) {
+			return strings.TrimPrefix(line, headerContent) == hashSource(src[i+2:])
+		}
+	}
+	return false
+}
+
+// finish fixes the imports of src, the generated file to be saved at
+// filename, and seals it.
+func finish(filename string, src []byte) ([]byte, error) {
+	b, err := FixImports(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return seal(b), nil
+}
+
+// ParseHeader returns the go-generate version and the provenance recorded
+// in the header of a generated file. It reports false if src was not
+// generated by go-generate or has no provenance.
+func ParseHeader(src []byte) (version string, provs []Provenance, ok bool) {
+	if !bytes.HasPrefix(src, []byte(strings.TrimSuffix(header, "\n"))) {
+		return "", nil, false
+	}
+	s := bufio.NewScanner(bytes.NewReader(src))
+	s.Scan()
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, headerVersion):
+			version = strings.TrimPrefix(line, headerVersion)
+		case strings.HasPrefix(line, headerInstance):
+			parts := strings.SplitN(strings.TrimPrefix(line, headerInstance), " ", 3)
+			if len(parts) != 3 {
+				return "", nil, false
+			}
+			provs = append(provs, Provenance{Generator: parts[0], Name: parts[1], Type: parts[2]})
+		case strings.HasPrefix(line, headerSource):
+			if len(provs) == 0 || provs[len(provs)-1].Source.File != "" {
+				provs = append(provs, Provenance{})
+			}
+			p := &provs[len(provs)-1]
+			src := strings.SplitN(strings.TrimPrefix(line, headerSource), " ", 2)
+			if i := strings.LastIndex(src[0], "@"); i >= 0 && len(src) == 2 {
+				p.Source = Source{Module: src[0][:i], Version: src[0][i+1:], File: src[1]}
+			}
+		case strings.HasPrefix(line, headerHash):
+			if len(provs) > 0 {
+				provs[len(provs)-1].Hash = strings.TrimPrefix(line, headerHash)
+			}
+		}
+	}
+	return version, provs, version != "" && len(provs) > 0
+}
+
+// UpToDate reports whether existing, a previously generated file, records
+// the same go-generate version, instantiations and upstream content hashes
+// that generating reqs would produce, and was not edited since. It only
+// hashes the upstream sources and does not run any mutation.
+func UpToDate(existing []byte, reqs ...Request) (bool, error) {
+	version, provs, ok := ParseHeader(existing)
+	if !ok || version != Version || len(provs) != len(reqs) || !sealed(existing) {
+		return false, nil
+	}
+	if f, err := parser.ParseFile(token.NewFileSet(), "", existing, parser.PackageClauseOnly); err != nil {
+		return false, nil
+	} else if len(reqs) > 0 && f.Name.Name != reqs[0].Package {
+		return false, nil
+	}
+	for i, r := range reqs {
+		factory, ok := Lookup(r.Generator)
+		if !ok {
+			return false, unknownFactory(r.Generator)
+		}
+		g, err := factory.New(r.Options)
+		if err != nil {
+			return false, err
+		}
+		g.SetOrigin(r.Generator, r.Options)
+		want := g.Provenance()
+		b, err := want.Source.Read()
+		if err != nil {
+			return false, &Error{Kind: SourceNotFound, Msg: fmt.Sprintf("read %q file", want.Source.Path), Err: err}
+		}
+		want.Hash = hashSource(b)
+		want.Source.Path, want.Source.Embedded = "", false
+		if provs[i] != want {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"bytes"
+	"go/ast"
+	"strings"
+	"testing"
+)
+
+// copyImpl is an Implementation copying its upstream source unchanged.
+type copyImpl struct{}
+
+func (copyImpl) Values() map[string]func(*ast.ValueSpec) { return nil }
+func (copyImpl) Types() map[string]func(*ast.TypeSpec)   { return nil }
+func (copyImpl) Funcs() map[string]func(*ast.FuncDecl)   { return nil }
+func (copyImpl) Mutate() error                           { return nil }
+
+// registerCopy registers the generator copy/list copying the embedded
+// snapshot of container/list, until the end of the test. Its
+// instantiations bundle into one, their declarations being identical.
+func registerCopy(t *testing.T) {
+	saved := factories
+	t.Cleanup(func() { factories = saved })
+	factories = map[string]Factory{}
+	Register(Factory{Name: "copy/list", New: func(o Options) (*Generator, error) {
+		return New(o.Package, Snapshot("std", "src/container/list/list.go"), copyImpl{})
+	}})
+}
+
+// setVersion sets Version until the end of the test.
+func setVersion(t *testing.T, v string) {
+	saved := Version
+	t.Cleanup(func() { Version = saved })
+	Version = v
+}
+
+func TestUpToDate(t *testing.T) {
+	registerCopy(t)
+	setVersion(t, "test")
+	reqs := []Request{
+		{Options: Options{Name: "L", Package: "p", Type: "int"}, Generator: "copy/list"},
+		{Options: Options{Name: "M", Package: "p", Type: "int"}, Generator: "copy/list"},
+	}
+	src, err := Bundle("l_gen.go", reqs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upToDate := func(src []byte, reqs ...Request) bool {
+		t.Helper()
+		ok, err := UpToDate(src, reqs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !upToDate(src, reqs...) {
+		t.Fatalf("the generated file is not up to date:\n%s", src)
+	}
+
+	edited := append(append([]byte(nil), src...), "// x\n"...)
+	if upToDate(edited, reqs...) {
+		t.Error("a file edited after the header is up to date")
+	}
+	if upToDate(bytes.Replace(src, []byte("instance: copy/list L int"), []byte("instance: copy/list L  int"), 1), reqs...) {
+		t.Error("a file with an edited header is up to date")
+	}
+	if upToDate(src, reqs[1], reqs[0]) {
+		t.Error("the file is up to date with the instances reordered")
+	}
+	if upToDate(src, reqs[0]) {
+		t.Error("the file is up to date with an instance less")
+	}
+	other := append([]Request(nil), reqs...)
+	other[0].Type = "string"
+	if upToDate(src, other...) {
+		t.Error("the file is up to date with another type argument")
+	}
+	other[0].Type, other[0].Package = "int", "q"
+	if upToDate(src, other...) {
+		t.Error("the file is up to date in another package")
+	}
+	setVersion(t, "other")
+	if upToDate(src, reqs...) {
+		t.Error("the file is up to date with another go-generate version")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	setVersion(t, "v1.2.3")
+	list := Provenance{Generator: "sync/map", Name: "M", Type: "map[string]*T", Source: Source{Module: "std", Version: "go1.21.5", File: "src/sync/map.go"}, Hash: "sha256:aa"}
+	ring := Provenance{Generator: "container/ring", Name: "R", Type: "int", Source: Source{Module: "std", Version: "go1.21.5", File: "src/container/ring/ring.go"}, Hash: "sha256:bb"}
+	good := string(seal(append(Header(list, ring), "package p\n"...)))
+
+	version, provs, ok := ParseHeader([]byte(good))
+	if !ok || version != "v1.2.3" || len(provs) != 2 || provs[0] != list || provs[1] != ring {
+		t.Errorf("ParseHeader(%q) = %q, %+v, %v; want v1.2.3, [%+v %+v], true", good, version, provs, ok, list, ring)
+	}
+	if !sealed([]byte(good)) {
+		t.Errorf("%q is not sealed", good)
+	}
+
+	for _, c := range []struct {
+		name, src string
+	}{
+		{"empty", ""},
+		{"not generated", "// Package p is hand written.\npackage p\n"},
+		{"generated by another tool", "// Code generated by stringer; DO NOT EDIT.\n\npackage p\n"},
+		{"without version", strings.Replace(good, "// go-generate: v1.2.3\n", "", 1)},
+		{"without instance", strings.SplitN(good, "// instance:", 2)[0] + "\npackage p\n"},
+		{"truncated instance", strings.Replace(good, "// instance: container/ring R int", "// instance: container/ring", 1)},
+	} {
+		if version, provs, ok := ParseHeader([]byte(c.src)); ok {
+			t.Errorf("%s: ParseHeader(%q) = %q, %+v, true; want false", c.name, c.src, version, provs)
+		}
+	}
+	for _, src := range []string{"package p\n", strings.Replace(good, "package p", "package q", 1), strings.Replace(good, "// content: ", "// content: x", 1)} {
+		if sealed([]byte(src)) {
+			t.Errorf("%q is sealed", src)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Options holds the settings of a single instantiation.
+type Options struct {
+	Name    string // name of the generated type.
+	Package string // package name of the generated file.
+	Type    string // type expression holding the type arguments.
+	Version string // upstream module version, if the generator supports it.
+	// GoVersion, if set, is the Go release standard library sources are
+	// taken from, such as "go1.21.5".
+	GoVersion string
+	// Upstream is Embedded or Live, where upstream files are read from.
+	// It defaults to Embedded.
+	Upstream string
+}
+
+// Factory describes a generator that can be selected by name.
+type Factory struct {
+	Name        string // name used to select the generator, e.g. "sync/map".
+	Description string // one line summary of what is generated.
+	Args        string // expected type argument syntax, e.g. "map[K]V".
+	New         func(Options) (*Generator, error)
+}
+
+var factories = map[string]Factory{}
+
+// Register makes a generator available by its name.
+// It panics if the name is empty or registered twice.
+func Register(f Factory) {
+	if f.Name == "" || f.New == nil {
+		panic("go-generate: Register requires a name and a constructor")
+	}
+	if _, ok := factories[f.Name]; ok {
+		panic(fmt.Sprintf("go-generate: generator %q registered twice", f.Name))
+	}
+	factories[f.Name] = f
+}
+
+// Lookup returns the generator registered under name.
+func Lookup(name string) (Factory, bool) {
+	f, ok := factories[name]
+	return f, ok
+}
+
+// Factories returns all registered generators sorted by name.
+func Factories() []Factory {
+	list := make([]Factory, 0, len(factories))
+	for _, f := range factories {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Suggest returns the names of registered generators close to name,
+// either by the smallest edit distance or by sharing the last path element.
+func Suggest(name string) []string {
+	var names []string
+	best := -1
+	for _, f := range Factories() {
+		d := distance(strings.ToLower(name), strings.ToLower(f.Name))
+		if path.Base(f.Name) == path.Base(name) {
+			d = 0
+		}
+		if d > len(f.Name)/3 || (best >= 0 && d > best) {
+			continue
+		}
+		if d < best {
+			names = names[:0]
+		}
+		best = d
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// distance computes the levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
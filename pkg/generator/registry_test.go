@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+// registerTest replaces the registered generators with factories of names
+// until the end of the test.
+func registerTest(t *testing.T, names ...string) {
+	saved := factories
+	t.Cleanup(func() { factories = saved })
+	factories = map[string]Factory{}
+	for _, name := range names {
+		Register(Factory{Name: name, New: func(Options) (*Generator, error) { return nil, nil }})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	registerTest(t, "sync/map", "container/list", "container/ring", "container/heap", "singleflight")
+	for _, c := range []struct {
+		name string
+		want []string
+	}{
+		{"sync/mpa", []string{"sync/map"}},
+		{"Sync/Map", []string{"sync/map"}},
+		{"map", []string{"sync/map"}},                      // same last element.
+		{"std/container/list", []string{"container/list"}}, // same last element.
+		{"container/lst", []string{"container/list"}},
+		{"singleflite", []string{"singleflight"}},
+		// the closest names only, sorted.
+		{"container/x", []string{"container/heap", "container/list", "container/ring"}},
+		{"container/lis", []string{"container/list"}},
+		// up to a third of the length of the name may differ.
+		{"sync/m", []string{"sync/map"}},
+		{"sync/", nil},
+		{"flight", nil},
+		{"", nil},
+	} {
+		if got := Suggest(c.name); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Suggest(%q) = %q; want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"abc", "acb", 2},
+		{"kitten", "sitting", 3},
+	} {
+		if got := distance(c.a, c.b); got != c.want {
+			t.Errorf("distance(%q, %q) = %d; want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Request is a single instantiation of a registered generator. Generators
+// register themselves when their package is imported, so programs using
+// Request import the generator packages they need for their side effects.
+type Request struct {
+	Options
+	Generator string // registered generator name, e.g. "sync/map".
+	// Filename is the path the generated file is meant to be saved at.
+	// Imports are resolved relative to it. It may be empty.
+	Filename string
+	// Cache, if not nil, shares parsed upstream sources between requests.
+	Cache *Cache
+	// TypeCheck resolves the type arguments in the package of Filename
+	// before generating, failing early on undefined or invalid types.
+	TypeCheck bool
+	// Strict fails generation when the upstream source drifted from the
+	// handlers of the generator.
+	Strict bool
+	// Pos is where the instantiation was requested, such as the position
+	// of a go:generate directive. It may be zero.
+	Pos token.Position
+}
+
+// Generate runs the whole pipeline in memory and returns the final file.
+func (r Request) Generate() ([]byte, error) {
+	b, err := r.generate()
+	if err != nil {
+		return nil, err
+	}
+	return finish(r.Filename, b)
+}
+
+// generate returns the generated file before its imports are fixed.
+func (r Request) generate() ([]byte, error) {
+	g, err := r.mutate()
+	if err != nil {
+		return nil, err
+	}
+	return g.Generate()
+}
+
+// mutate returns the generator of the request once it has mutated the
+// upstream source.
+func (r Request) mutate() (*Generator, error) {
+	factory, ok := Lookup(r.Generator)
+	if !ok {
+		return nil, unknownFactory(r.Generator)
+	}
+	if !token.IsIdentifier(r.Name) {
+		return nil, Errorf(BadArgument, "invalid name %q, expected an identifier", r.Name).At(r.Pos)
+	}
+	imports, reserved, err := r.resolve()
+	if err != nil {
+		return nil, r.at(err)
+	}
+	opts := r.Options
+	if len(imports) > 0 {
+		opts.Type = requalify(opts.Type, placeholders(imports))
+	}
+	g, err := factory.New(opts)
+	if err != nil {
+		return nil, r.at(err)
+	}
+	g.SetOrigin(r.Generator, r.Options)
+	if r.Cache != nil {
+		g.SetCache(r.Cache)
+	}
+	g.SetStrict(r.Strict)
+	if err := g.Mutate(); err != nil {
+		return nil, r.at(err)
+	}
+	g.qualify(imports, reserved)
+	return g, nil
+}
+
+// at positions err at the request unless its position is known.
+func (r Request) at(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		e.At(r.Pos)
+	}
+	return err
+}
+
+// WriteTo generates the file and writes it to w.
+func (r Request) WriteTo(w io.Writer) (int64, error) {
+	b, err := r.Generate()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	if err != nil {
+		return int64(n), &Error{Kind: WriteFailed, Msg: "write generated file", Err: err}
+	}
+	return int64(n), nil
+}
+
+// unknownFactory describes a missing generator along with the closest
+// registered names.
+func unknownFactory(name string) error {
+	msg := fmt.Sprintf("generator %q does not exist", name)
+	if names := Suggest(name); len(names) > 0 {
+		msg += fmt.Sprintf("; did you mean %s?", strings.Join(names, " or "))
+	}
+	return Errorf(BadArgument, "%s", msg)
+}
+
+// Source returns the upstream file the request is generated from.
+func (r Request) Source() (Source, error) {
+	factory, ok := Lookup(r.Generator)
+	if !ok {
+		return Source{}, unknownFactory(r.Generator)
+	}
+	g, err := factory.New(r.Options)
+	if err != nil {
+		return Source{}, err
+	}
+	return g.Provenance().Source, nil
+}
+
+// Notes returns the choices the generator makes on behalf of the user for
+// the request, such as a substituted source.
+func (r Request) Notes() ([]string, error) {
+	factory, ok := Lookup(r.Generator)
+	if !ok {
+		return nil, unknownFactory(r.Generator)
+	}
+	g, err := factory.New(r.Options)
+	if err != nil {
+		return nil, err
+	}
+	return g.Notes(), nil
+}
+
+// resolve finds the packages qualifying the type arguments and checks
+// the type arguments if requested. It also returns the names declared in
+// the target package, which imports of the generated file must not use.
+func (r Request) resolve() (imports []Import, reserved []string, err error) {
+	defer Catch(&err)
+	if len(qualifiers(ParseType(r.Type))) == 0 && !r.TypeCheck {
+		return nil, nil, nil
+	}
+	target, err := r.Cache.target(filepath.Dir(r.Filename))
+	if err != nil {
+		return nil, nil, err
+	}
+	if imports, err = target.Resolve(r.Type, r.Pos); err != nil {
+		return nil, nil, err
+	}
+	if r.TypeCheck {
+		if _, err := target.Check(r.Type, imports, r.Pos); err != nil {
+			return nil, nil, err
+		}
+	}
+	return imports, target.Pkg.Types.Scope().Names(), nil
+}
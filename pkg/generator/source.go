@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"bufio"
+	"go/build"
+	goversion "go/version"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/snapshot"
+)
+
+// Upstream selects where generators read their upstream files from.
+const (
+	Embedded = "embedded" // the snapshots built into go-generate, the default.
+	Live     = "live"     // the installed Go toolchain and module cache.
+)
+
+// Source is the upstream file mutated by a generator.
+type Source struct {
+	Path     string // location of the file on disk, or name of the snapshot.
+	Module   string // module holding the file, "std" for the standard library.
+	Version  string // version of the module.
+	File     string // slash separated path of the file within the module.
+	Embedded bool   // the file is read from the snapshots of go-generate.
+}
+
+// String identifies the source independently of where it is stored.
+func (s Source) String() string {
+	return s.Module + "@" + s.Version + " " + s.File
+}
+
+// UseSnapshot reports whether o selects the embedded snapshots.
+func UseSnapshot(o Options) bool {
+	switch o.Upstream {
+	case "", Embedded:
+		return true
+	case Live:
+		return false
+	}
+	panic(Errorf(BadArgument, "invalid upstream %q, expected %s or %s", o.Upstream, Embedded, Live))
+}
+
+// StdSource returns the standard library file at file, a slash separated
+// path relative to GOROOT. It comes from the release o.GoVersion if set, or
+// else from the snapshot or the installed toolchain selected by o.
+func StdSource(o Options, file string) Source {
+	if o.GoVersion != "" {
+		return releaseSource(o, file)
+	}
+	if UseSnapshot(o) {
+		return Snapshot("std", file)
+	}
+	return GoSource(file)
+}
+
+// releaseSource returns file of the Go release o.GoVersion, from the
+// embedded snapshot if it has that version, or else from a toolchain
+// downloaded to the module cache.
+func releaseSource(o Options, file string) Source {
+	v := o.GoVersion
+	if !strings.HasPrefix(v, "go") {
+		v = "go" + v
+	}
+	ExpectKind(BadArgument, goversion.IsValid(v), "invalid Go version %q", o.GoVersion)
+	ExpectKind(BadArgument, goversion.Lang(v) != v || goversion.Compare(v, "go1.21") < 0,
+		"%s is a language version, name a release such as %s.0", v, v)
+	if UseSnapshot(o) && v == snapshot.Go {
+		return Snapshot("std", file)
+	}
+	if live := GoSource(file); live.Version == v {
+		return live
+	}
+	root, ok := toolchain(v)
+	ExpectKind(SourceNotFound, ok, "%s is not in the module cache, download it with \"GOTOOLCHAIN=%s go version\"", v, v)
+	return Source{
+		Path:    filepath.Join(root, filepath.FromSlash(file)),
+		Module:  "std",
+		Version: v,
+		File:    path.Clean(file),
+	}
+}
+
+// toolchain returns the directory of the Go release v in the module cache,
+// preferring the build for the current platform. The sources of every
+// platform are the same.
+func toolchain(v string) (string, bool) {
+	dir := filepath.Join(ModCache(), "golang.org")
+	prefix := "toolchain@v0.0.1-" + v + "."
+	if root := filepath.Join(dir, prefix+runtime.GOOS+"-"+runtime.GOARCH); isDir(root) {
+		return root, true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, prefix+"*"))
+	sort.Strings(matches)
+	for _, m := range matches {
+		// skip later patch releases of a language version, as in go1.21.13
+		// for go1.21.
+		if platform := strings.TrimPrefix(filepath.Base(m), prefix); !strings.Contains(platform, ".") && isDir(m) {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// ModCache returns the module cache directory, GOMODCACHE if set.
+func ModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.SplitList(build.Default.GOPATH)[0], "pkg", "mod")
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// Snapshot returns the embedded copy of file in module.
+func Snapshot(module, file string) Source {
+	version, ok := snapshot.Version(module)
+	ExpectKind(SourceNotFound, ok, "no snapshot of %s is embedded", module)
+	return Source{
+		Path:     path.Join("snapshot", module+"@"+version, file),
+		Module:   module,
+		Version:  version,
+		File:     path.Clean(file),
+		Embedded: true,
+	}
+}
+
+// Sibling returns the file at file, a slash separated path within the
+// same module and version as s, such as the tests of s.
+func (s Source) Sibling(file string) Source {
+	sib := s
+	sib.File = path.Clean(file)
+	if s.Embedded {
+		sib.Path = path.Join("snapshot", s.Module+"@"+s.Version, sib.File)
+	} else {
+		root := strings.TrimSuffix(filepath.ToSlash(s.Path), s.File)
+		sib.Path = filepath.FromSlash(root + sib.File)
+	}
+	return sib
+}
+
+// Read returns the content of the file.
+func (s Source) Read() ([]byte, error) {
+	if s.Embedded {
+		return snapshot.ReadFile(s.Module, s.Version, s.File)
+	}
+	return ioutil.ReadFile(s.Path)
+}
+
+// GoSource returns the standard library file at file, a slash separated
+// path relative to GOROOT, such as "src/sync/map.go".
+func GoSource(file string) Source {
+	root := runtime.GOROOT()
+	return Source{
+		Path:    filepath.Join(root, filepath.FromSlash(file)),
+		Module:  "std",
+		Version: goVersion(root),
+		File:    path.Clean(file),
+	}
+}
+
+// goVersion returns the Go version of the toolchain installed at root.
+func goVersion(root string) string {
+	f, err := os.Open(filepath.Join(root, "VERSION"))
+	if err != nil {
+		return runtime.Version()
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	if s.Scan() && strings.HasPrefix(s.Text(), "go") {
+		return strings.TrimSpace(s.Text())
+	}
+	return runtime.Version()
+}
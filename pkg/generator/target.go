@@ -0,0 +1,262 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Target is the type checked package generated code is written to. Type
+// arguments are resolved in its scope.
+type Target struct {
+	Dir string
+	Pkg *packages.Package
+
+	mu       sync.Mutex
+	known    map[string][]string       // package name => import paths in the module and std.
+	imported map[string]*types.Package // packages loaded for qualified type arguments.
+	deps     map[string]*types.Package // packages the target depends on.
+}
+
+// Import is a package referenced by a qualified type argument.
+type Import struct {
+	Name    string // qualifier used in the type argument.
+	Path    string // import path of the package.
+	Package string // declared name of the package.
+}
+
+// LoadTarget loads and type checks the package in dir. Type errors in the
+// package are tolerated, since they are often caused by generated code
+// that is missing or out of date.
+func LoadTarget(dir string) (*Target, error) {
+	dir = absPath(dir)
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedModule,
+		Dir:   dir,
+		Tests: false,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, &Error{Kind: BadArgument, Msg: fmt.Sprintf("load package in %s", dir), Err: err}
+	}
+	if len(pkgs) != 1 || pkgs[0].Types == nil || pkgs[0].Fset == nil {
+		return nil, Errorf(BadArgument, "load package in %s: no type information", dir)
+	}
+	return &Target{Dir: dir, Pkg: pkgs[0], imported: map[string]*types.Package{}}, nil
+}
+
+// Check resolves the type expression expr in the package scope, with the
+// qualifiers of imports bound to their packages.
+func (t *Target) Check(expr string, imports []Import, at token.Position) (types.Type, error) {
+	pkg := types.NewPackage(t.Pkg.Types.Path(), t.Pkg.Types.Name())
+	scope := t.Pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		pkg.Scope().Insert(scope.Lookup(name))
+	}
+	for _, imp := range imports {
+		p, err := t.load(imp.Path)
+		if err != nil {
+			return nil, err.At(at)
+		}
+		pkg.Scope().Insert(types.NewPkgName(token.NoPos, pkg, imp.Name, p))
+	}
+
+	tv, err := types.Eval(t.Pkg.Fset, pkg, token.NoPos, expr)
+	if err != nil {
+		return nil, &Error{Kind: BadArgument, Pos: at, Msg: fmt.Sprintf("invalid type argument %q", expr), Err: err}
+	}
+	if !tv.IsType() {
+		return nil, Errorf(BadArgument, "type argument %q is not a type", expr).At(at)
+	}
+	return tv.Type, nil
+}
+
+// Resolve returns the packages named by the qualifiers in expr. They are
+// looked up in the imports of the file at at, then in the imports of the
+// other files of the package, and last among the packages of the module
+// and the standard library.
+func (t *Target) Resolve(expr string, at token.Position) (imports []Import, err error) {
+	defer Catch(&err)
+	for _, name := range qualifiers(ParseType(expr)) {
+		imp, err := t.resolve(name, at)
+		if err != nil {
+			return nil, err.At(at)
+		}
+		imports = append(imports, imp)
+	}
+	return imports, nil
+}
+
+func (t *Target) resolve(name string, at token.Position) (Import, *Error) {
+	var inFile, inPackage []string
+	for i, f := range t.Pkg.Syntax {
+		local := at.Filename != "" && i < len(t.Pkg.CompiledGoFiles) && sameFile(t.Pkg.CompiledGoFiles[i], at.Filename)
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if t.importName(path, spec.Name) != name {
+				continue
+			}
+			if local {
+				inFile = append(inFile, path)
+			} else if !contains(inPackage, path) {
+				inPackage = append(inPackage, path)
+			}
+		}
+	}
+	paths := inFile
+	if len(paths) == 0 {
+		paths = inPackage
+	}
+	if len(paths) == 0 {
+		known, err := t.packages()
+		if err != nil {
+			return Import{}, err
+		}
+		paths = known[name]
+	}
+
+	switch len(paths) {
+	case 0:
+		return Import{}, Errorf(BadArgument, "package %s is not imported by package %s and is not in its module or the standard library", name, t.Pkg.Name)
+	case 1:
+		p, err := t.load(paths[0])
+		if err != nil {
+			return Import{}, err
+		}
+		return Import{Name: name, Path: paths[0], Package: p.Name()}, nil
+	default:
+		return Import{}, Errorf(BadArgument, "package %s is ambiguous: it may be %s; import the intended one in the file of the directive", name, strings.Join(paths, " or "))
+	}
+}
+
+// importName returns the name an import spec binds in its file, or "" for
+// blank and dot imports.
+func (t *Target) importName(path string, name *ast.Ident) string {
+	if name != nil {
+		if name.Name == "_" || name.Name == "." {
+			return ""
+		}
+		return name.Name
+	}
+	if p, ok := t.Pkg.Imports[path]; ok {
+		return p.Name
+	}
+	return ""
+}
+
+// packages returns the import paths of the packages in the module of the
+// target and in the standard library, by package name.
+func (t *Target) packages() (map[string][]string, *Error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.known != nil {
+		return t.known, nil
+	}
+	patterns := []string{"std"}
+	if t.Pkg.Module != nil {
+		patterns = append(patterns, t.Pkg.Module.Path+"/...")
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName, Dir: t.Dir}, patterns...)
+	if err != nil {
+		return nil, &Error{Kind: BadArgument, Msg: "list packages", Err: err}
+	}
+	known := map[string][]string{}
+	for _, p := range pkgs {
+		if p.PkgPath == t.Pkg.PkgPath || p.Name == "main" || !importable(t.Pkg.PkgPath, p.PkgPath) {
+			continue
+		}
+		known[p.Name] = append(known[p.Name], p.PkgPath)
+	}
+	for _, paths := range known {
+		sort.Strings(paths)
+	}
+	t.known = known
+	return known, nil
+}
+
+// Import returns the type checked package at path, making Target the
+// importer of code type checked as part of the package.
+func (t *Target) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	p, err := t.load(path)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// load returns the type checked package at path, sharing the packages
+// the target depends on.
+func (t *Target) load(path string) (*types.Package, *Error) {
+	if p, ok := t.Pkg.Imports[path]; ok && p.Types != nil {
+		return p.Types, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.deps == nil {
+		t.deps = map[string]*types.Package{}
+		packages.Visit([]*packages.Package{t.Pkg}, nil, func(p *packages.Package) {
+			if p.Types != nil {
+				t.deps[p.PkgPath] = p.Types
+			}
+		})
+	}
+	if p, ok := t.deps[path]; ok {
+		return p, nil
+	}
+	if p, ok := t.imported[path]; ok {
+		return p, nil
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps, Dir: t.Dir}, path)
+	if err != nil {
+		return nil, &Error{Kind: BadArgument, Msg: fmt.Sprintf("load package %s", path), Err: err}
+	}
+	if len(pkgs) != 1 || pkgs[0].Types == nil || len(pkgs[0].Errors) > 0 {
+		return nil, Errorf(BadArgument, "load package %s: no type information", path)
+	}
+	t.imported[path] = pkgs[0].Types
+	return pkgs[0].Types, nil
+}
+
+// importable reports whether the package at from may import path, taking
+// internal and vendored packages into account.
+func importable(from, path string) bool {
+	elems := strings.Split(path, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		switch elems[i] {
+		case "vendor":
+			return false
+		case "internal":
+			parent := strings.Join(elems[:i], "/")
+			return parent != "" && (from == parent || strings.HasPrefix(from, parent+"/"))
+		}
+	}
+	return true
+}
+
+// sameFile reports whether path and name are the same file. Relative
+// paths are relative to the working directory, as are the positions of
+// directives and the output paths.
+func sameFile(path, name string) bool {
+	return absPath(path) == absPath(name)
+}
+
+// absPath returns the absolute form of path, or path cleaned if the working
+// directory is unknown.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
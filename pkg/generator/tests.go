@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"strings"
+	"text/template"
+)
+
+// Tester is implemented by generators writing a companion test of their
+// instantiations. Test returns the imports and declarations of a test
+// file of the package of the generated file, exercising its API with the
+// type arguments.
+type Tester interface {
+	Test() string
+}
+
+// testTemplates are shared by the tests of the generators. The "value"
+// template, applied to a type, is a function returning random values of
+// the type, or new pointers if the pointed type can not be generated; it
+// uses rnd and t and imports reflect and testing/quick.
+const testTemplates = `{{define "value"}}func() (v {{.}}) {
+		typ := reflect.TypeOf(&v).Elem()
+		defer func() {
+			if r := recover(); r != nil {
+				if typ.Kind() != reflect.Ptr {
+					t.Skipf("testing/quick can not generate values of %v: %v", typ, r)
+				}
+				v = reflect.New(typ.Elem()).Interface().({{.}})
+			}
+		}()
+		rv, ok := quick.Value(typ, rnd)
+		if !ok {
+			t.Skipf("testing/quick can not generate values of %v", typ)
+		}
+		return rv.Interface().({{.}})
+	}{{end}}`
+
+// RenderTest executes text, the text/template of a companion test, with
+// data. defs are the texts of other templates text uses, along with the
+// shared ones.
+func RenderTest(text string, data interface{}, defs ...string) string {
+	tmpl := template.Must(template.New("test").Parse(testTemplates))
+	for _, def := range defs {
+		_, err := tmpl.Parse(def)
+		Check(err, "parse test template")
+	}
+	tmpl, err := tmpl.Parse(text)
+	Check(err, "parse test template")
+	b := &strings.Builder{}
+	Check(tmpl.Execute(b, data), "render test template")
+	return b.String()
+}
+
+// companion is a kind of test file written next to the generated file.
+type companion struct {
+	kind   string // what the file holds, such as "tests".
+	label  string // labels the declarations of each request when merging.
+	render func(Implementation) (string, bool)
+}
+
+// tests is the companion test exercising the generated API.
+var tests = companion{"tests", "test", func(impl Implementation) (string, bool) {
+	t, ok := impl.(Tester)
+	if !ok {
+		return "", false
+	}
+	return t.Test(), true
+}}
+
+// companion returns the companion file c of the mutated file, without
+// header, with the type arguments qualified as in the file. ok is false if
+// the generator does not write c.
+func (g *Generator) companion(c companion) (out []byte, ok bool, err error) {
+	defer Catch(&err)
+	text, ok := c.render(g.impl)
+	if !ok {
+		return nil, false, nil
+	}
+	src := "package " + g.pkg + "\n\n" + text
+	f, err := parser.ParseFile(g.fset, g.origin.Generator+" "+c.label, src, parser.ParseComments)
+	Check(err, "parse companion "+c.label)
+
+	// the test shares the package scope with the generated file.
+	reserved := append([]string(nil), g.reserved...)
+	for _, d := range g.file.Decls {
+		for _, name := range declNames(d) {
+			if !strings.Contains(name, ".") {
+				reserved = append(reserved, name)
+			}
+		}
+	}
+	qualifyFile(g.fset, f, g.imports, reserved)
+
+	b := &bytes.Buffer{}
+	Check(format.Node(b, g.fset, f), "format companion "+c.label)
+	return b.Bytes(), true, nil
+}
+
+// bundleCompanion returns the companion file c of the file generated by
+// Bundle for reqs, to be saved at filename.
+func bundleCompanion(c companion, filename string, reqs ...Request) ([]byte, error) {
+	files := make([][]byte, 0, len(reqs))
+	labels := make([]string, 0, len(reqs))
+	provs := make([]Provenance, 0, len(reqs))
+	for _, r := range reqs {
+		r.Filename = filename
+		g, err := r.mutate()
+		if err != nil {
+			return nil, err
+		}
+		b, ok, err := g.companion(c)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, Errorf(BadArgument, "generator %s does not write %s", r.Generator, c.kind).At(r.Pos)
+		}
+		files = append(files, b)
+		labels = append(labels, fmt.Sprintf("%s of %s %s", c.label, r.Generator, r.Name))
+		provs = append(provs, g.Provenance())
+	}
+	b, err := merge(files, labels)
+	if err != nil {
+		return nil, err
+	}
+	return finish(filename, append(Header(provs...), b...))
+}
+
+// BundleTests returns the companion test of the file generated by
+// Bundle for reqs, to be saved at filename. Every generator of reqs must
+// write tests.
+func BundleTests(filename string, reqs ...Request) ([]byte, error) {
+	return bundleCompanion(tests, filename, reqs...)
+}
+
+// TestFile returns the path of the companion test of the generated file
+// at filename.
+func TestFile(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_test.go"
+}
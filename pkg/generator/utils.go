@@ -12,16 +12,40 @@ import (
 	"golang.org/x/tools/go/ast/astutil"
 )
 
+// ReplaceIface replaces the interface types of n, including the predeclared
+// any, with the type expression s.
 func ReplaceIface(n ast.Node, s string) {
 	astutil.Apply(n, func(c *astutil.Cursor) bool {
-		n := c.Node()
-		if it, ok := n.(*ast.InterfaceType); ok {
-			c.Replace(Expr(s, it.Interface))
+		switch n := c.Node().(type) {
+		case *ast.InterfaceType:
+			c.Replace(Expr(s, n.Interface))
+		case *ast.Ident:
+			if _, sel := c.Parent().(*ast.SelectorExpr); n.Name == "any" && n.Obj == nil && !sel {
+				c.Replace(Expr(s, n.NamePos))
+			}
 		}
 		return true
 	}, nil)
 }
 
+// mentionsIface reports whether n mentions the empty interface, spelled
+// interface{} or any.
+func mentionsIface(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.InterfaceType:
+			found = found || n.Methods == nil || len(n.Methods.List) == 0
+		case *ast.SelectorExpr:
+			return false
+		case *ast.Ident:
+			found = found || n.Name == "any" && n.Obj == nil
+		}
+		return !found
+	})
+	return found
+}
+
 func RenameNil(n ast.Node, name string) {
 	astutil.Apply(n, func(c *astutil.Cursor) bool {
 		if _, ok := c.Parent().(*ast.ReturnStmt); ok {
@@ -37,9 +61,15 @@ func Rename(f *ast.File, oldnew map[string]string) {
 	astutil.Apply(f, func(c *astutil.Cursor) bool {
 		switch n := c.Node().(type) {
 		case *ast.Ident:
+			if sel, ok := c.Parent().(*ast.SelectorExpr); ok && sel.Sel == n {
+				// a field, method or qualified identifier.
+				return true
+			}
 			if name, ok := oldnew[n.Name]; ok {
 				n.Name = name
-				n.Obj.Name = name
+				if n.Obj != nil {
+					n.Obj.Name = name
+				}
 			}
 		case *ast.FuncDecl:
 			if name, ok := oldnew[n.Name.Name]; ok {
@@ -99,38 +129,64 @@ func SetPos(n ast.Node, p token.Pos) {
 		n.Star = p
 		SetPos(n.X, p)
 	case *ast.ChanType:
+		n.Begin = p
 		SetPos(n.Value, p)
+	case *ast.Ellipsis:
+		n.Ellipsis = p
+		SetPos(n.Elt, p)
 	case *ast.ParenExpr:
 		SetPos(n.X, p)
+	case *ast.IndexExpr:
+		SetPos(n.X, p)
+		n.Lbrack = p
+		SetPos(n.Index, p)
+		n.Rbrack = p
+	case *ast.IndexListExpr:
+		SetPos(n.X, p)
+		n.Lbrack = p
+		for _, index := range n.Indices {
+			SetPos(index, p)
+		}
+		n.Rbrack = p
 	default:
-		panic(fmt.Sprintf("unknown type: %v", n))
+		if e, ok := n.(ast.Expr); ok {
+			panic(Errorf(BadArgument, "unsupported type expression %s", types.ExprString(e)))
+		}
+		panic(Errorf(BadArgument, "unsupported node %T in type expression", n))
 	}
 }
 
 // Check panics if the error is not nil.
+// Errors already carrying a kind keep it, others are internal errors.
 func Check(err error, msg string, args ...interface{}) {
 	if err != nil {
-		args = append(args, err)
-		panic(genError{fmt.Sprintf(msg+": %s", args...)})
+		CheckKind(KindOf(err), err, msg, args...)
 	}
 }
 
-// Expect panic if the condition is false.
-func Expect(cond bool, msg string, args ...interface{}) {
-	if !cond {
-		panic(genError{fmt.Sprintf(msg, args...)})
+// CheckKind panics with an error of the given kind if err is not nil.
+func CheckKind(kind Kind, err error, msg string, args ...interface{}) {
+	if err != nil {
+		panic(&Error{Kind: kind, Msg: fmt.Sprintf(msg, args...), Err: err})
 	}
 }
 
-type genError struct {
-	msg string
+// Expect panic if the condition is false.
+func Expect(cond bool, msg string, args ...interface{}) {
+	ExpectKind(Internal, cond, msg, args...)
 }
 
-func (p genError) Error() string { return fmt.Sprintf("go-generate: %s", p.msg) }
+// ExpectKind panics with an error of the given kind if the condition is false.
+func ExpectKind(kind Kind, cond bool, msg string, args ...interface{}) {
+	if !cond {
+		panic(Errorf(kind, msg, args...))
+	}
+}
 
+// Catch recovers errors raised by Check and Expect into err.
 func Catch(err *error) {
 	if e := recover(); e != nil {
-		gerr, ok := e.(genError)
+		gerr, ok := e.(*Error)
 		if !ok {
 			panic(e)
 		}
@@ -138,9 +194,72 @@ func Catch(err *error) {
 	}
 }
 
+// ParseType parses a type expression given as a generator argument. It
+// fails if s is not a type expression the handlers can substitute.
+func ParseType(s string) ast.Expr {
+	exp, err := parser.ParseExpr(s)
+	CheckKind(BadArgument, err, "malformed type expression %q", s)
+	_, lit := exp.(*ast.BasicLit)
+	ExpectKind(BadArgument, !lit, "invalid type expression %q", s)
+	ast.Inspect(exp, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case nil, *ast.Ident, *ast.SelectorExpr, *ast.StarExpr, *ast.ParenExpr,
+			*ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType,
+			*ast.StructType, *ast.InterfaceType, *ast.FieldList, *ast.Field,
+			*ast.IndexExpr, *ast.IndexListExpr:
+		case *ast.BasicLit:
+			// lengths of arrays and tags of struct fields.
+		case *ast.Ellipsis:
+			// variadic parameters of function types.
+			ExpectKind(BadArgument, n.Elt != nil, "invalid type expression %q: array length ... is only valid in composite literals", s)
+		default:
+			panic(Errorf(BadArgument, "invalid type expression %q: %s is not a type", s, types.ExprString(n.(ast.Expr))))
+		}
+		return true
+	})
+	return exp
+}
+
+// qualifiers returns the package names qualifying identifiers in the type
+// expression e, in order of appearance.
+func qualifiers(e ast.Expr) []string {
+	var names []string
+	inspectQualifiers(e, func(id *ast.Ident) {
+		if !contains(names, id.Name) {
+			names = append(names, id.Name)
+		}
+	})
+	return names
+}
+
+// requalify renames the package qualifiers of the type expression s.
+func requalify(s string, oldnew map[string]string) string {
+	e := ParseType(s)
+	inspectQualifiers(e, func(id *ast.Ident) {
+		if name, ok := oldnew[id.Name]; ok {
+			id.Name = name
+		}
+	})
+	return types.ExprString(e)
+}
+
+func inspectQualifiers(e ast.Expr, fn func(*ast.Ident)) {
+	ast.Inspect(e, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok {
+			fn(id)
+		}
+		return false
+	})
+}
+
+// FailOnErr prints err as a single line and exits with its exit code.
 func FailOnErr(err error) {
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n\n", err.Error())
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ExitCode(err))
 	}
 }
@@ -0,0 +1,37 @@
+package generator
+
+import (
+	"go/token"
+	"testing"
+)
+
+func TestParseType(t *testing.T) {
+	for _, s := range []string{
+		"int", "*bytes.Buffer", "map[string][]*User", "[4]byte", "chan<- int",
+		"func(int, ...string) error", "struct{ A int `json:\"a\"` }", "interface{ M() }",
+		"Pair[int]", "Both[string, *model.User]", "(int)",
+	} {
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					t.Errorf("ParseType(%q) panicked: %v", s, e)
+				}
+			}()
+			e := ParseType(s)
+			// the handlers substitute the type at the position of the
+			// declarations.
+			SetPos(e, token.Pos(1))
+		}()
+	}
+	for _, s := range []string{"", "1", "f()", "a + b", "-x", "[...]int", "map[string]int{}", "func() {}"} {
+		func() {
+			defer func() {
+				e, ok := recover().(*Error)
+				if !ok || e.Kind != BadArgument {
+					t.Errorf("ParseType(%q) panicked with %v; want a bad argument", s, e)
+				}
+			}()
+			ParseType(s)
+		}()
+	}
+}
@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Verify type checks src, the file generated from reqs to be saved at
+// filename, together with the other files of the package in its
+// directory. Only errors in the generated file are reported; the first
+// one is attributed to the instantiation and handler which produced the
+// declaration holding it. It is a BadArgument if the declaration collides
+// with the package or was rewritten by a handler with the type arguments,
+// and a ShapeChanged if the upstream declaration has no handler or can
+// not be found.
+func Verify(filename string, src []byte, reqs ...Request) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	target, err := reqs[0].Cache.target(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	fset := target.Pkg.Fset
+	f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return &Error{Kind: Internal, Msg: "parse generated code", Err: err}
+	}
+
+	errs := target.typeErrors([]*ast.File{f}, filename)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msg := "generated code does not compile"
+	if len(errs) > 1 {
+		msg = fmt.Sprintf("generated code does not compile (%d errors)", len(errs))
+	}
+	origin, kind := attribute(f, errs[0].Pos, filename, reqs)
+	if origin != "" {
+		msg += "; " + origin
+	}
+	if redeclared(errs[0]) {
+		kind = BadArgument
+	}
+	return &Error{Kind: kind, Pos: fset.Position(errs[0].Pos), Msg: msg, Err: fmt.Errorf("%s", errs[0].Msg)}
+}
+
+// redeclared reports whether err is a generated declaration colliding with
+// another one, such as a declaration of the package or of another
+// instantiation of the same generator.
+func redeclared(err types.Error) bool {
+	return strings.Contains(err.Msg, "redeclared") || strings.Contains(err.Msg, "already declared")
+}
+
+// typeErrors type checks files with the other files of the package, the
+// files at exclude being left out, and returns the errors in files. files
+// come last, so that declarations conflicting with the rest of the
+// package are reported in them.
+func (t *Target) typeErrors(files []*ast.File, exclude ...string) []types.Error {
+	fset := t.Pkg.Fset
+	var all []*ast.File
+	for i, other := range t.Pkg.Syntax {
+		excluded := false
+		for _, name := range exclude {
+			excluded = excluded || i < len(t.Pkg.CompiledGoFiles) && sameFile(t.Pkg.CompiledGoFiles[i], name)
+		}
+		if !excluded {
+			all = append(all, other)
+		}
+	}
+	checked := map[*token.File]bool{}
+	for _, f := range files {
+		checked[fset.File(f.Pos())] = true
+		all = append(all, f)
+	}
+
+	var errs []types.Error
+	conf := types.Config{
+		Importer: t,
+		Error: func(err error) {
+			if te, ok := err.(types.Error); ok && !te.Soft && checked[fset.File(te.Pos)] {
+				errs = append(errs, te)
+			}
+		},
+	}
+	conf.Check(t.Pkg.PkgPath, fset, all, nil)
+	return errs
+}
+
+// attribute describes the instantiation and the upstream declaration and
+// handler that produced the generated declaration at pos, in the file
+// generated from reqs to be saved at filename. The kind is BadArgument if
+// a handler rewrote the declaration with the type arguments, and
+// ShapeChanged otherwise.
+func attribute(f *ast.File, pos token.Pos, filename string, reqs []Request) (string, Kind) {
+	var names []string
+	for _, d := range f.Decls {
+		if d.Pos() <= pos && pos < d.End() {
+			names = declNames(d)
+			break
+		}
+	}
+	if len(names) == 0 {
+		return "", ShapeChanged
+	}
+	for _, r := range reqs {
+		r.Filename = filename
+		g, err := r.mutate()
+		if err != nil {
+			continue
+		}
+		if h, ok := g.upstream(names[0]); ok {
+			desc := fmt.Sprintf("in %s %s from upstream %s %s (%s:%d)", r.Generator, r.Name, h.Kind, h.Name, path.Base(filepath.ToSlash(h.Pos.Filename)), h.Pos.Line)
+			if !g.handled(h) {
+				return desc + " which has no handler", ShapeChanged
+			}
+			return desc, BadArgument
+		}
+	}
+	return "", ShapeChanged
+}
+
+// upstream returns the upstream declaration which became the generated
+// declaration named name, as returned by declNames.
+func (g *Generator) upstream(name string) (Handler, bool) {
+	original := map[string]string{}
+	for old, name := range g.renames {
+		original[name] = old
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		if recv, ok := original[name[:i]]; ok {
+			name = recv + name[i:]
+		}
+	} else if old, ok := original[name]; ok {
+		name = old
+	}
+	h, ok := g.decls[name]
+	return h, ok
+}
+
+// handled reports whether a handler rewrote the upstream declaration h.
+func (g *Generator) handled(h Handler) bool {
+	for _, a := range g.applied {
+		if a.Pos == h.Pos {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// elemImpl is an Implementation copying its upstream source with the
+// value of list elements of the type argument. Its other declarations are
+// left unhandled, as if they had appeared upstream.
+type elemImpl struct {
+	copyImpl
+	typ string
+}
+
+func (g elemImpl) Types() map[string]func(*ast.TypeSpec) {
+	return map[string]func(*ast.TypeSpec){
+		"Element": func(n *ast.TypeSpec) { ReplaceIface(n, g.typ) },
+	}
+}
+
+func TestVerifyKind(t *testing.T) {
+	registerCopy(t)
+	Register(Factory{Name: "elem/list", New: func(o Options) (*Generator, error) {
+		return New(o.Package, Snapshot("std", "src/container/list/list.go"), elemImpl{typ: o.Type})
+	}})
+	for _, c := range []struct {
+		generator, typ, src string
+		want                Kind
+	}{
+		{"copy/list", "int", "func New() {}", BadArgument}, // New collides with the package.
+		{"elem/list", "undefinedType", "", BadArgument},    // the type argument is undefined.
+		{"elem/list", "int", "", ShapeChanged},             // insertValue has no handler.
+	} {
+		dir := t.TempDir()
+		for name, src := range map[string]string{
+			"go.mod": "module example.com/p\n\ngo 1.21\n",
+			"p.go":   "package p\n\n" + c.src + "\n",
+		} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		filename := filepath.Join(dir, "l_gen.go")
+		r := Request{Options: Options{Name: "L", Package: "p", Type: c.typ}, Generator: c.generator, Filename: filename, Cache: NewCache()}
+		src, err := r.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Verify(filename, src, r)
+		if err == nil {
+			t.Errorf("%s %s: generated code compiles", c.generator, c.typ)
+			continue
+		}
+		if kind := KindOf(err); kind != c.want {
+			t.Errorf("%s %s: Verify returned a %s, want a %s: %v", c.generator, c.typ, kind, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package manifest reads go-generate manifests, which describe many
+// instantiations of the registered generators in a single file.
+//
+// A manifest is either YAML (.yaml, .yml) or JSON (.json):
+//
+//	package: model
+//	generate:
+//	  - generator: sync/map
+//	    name: UserMap
+//	    type: map[string]*User
+//	  - generator: container/list
+//	    name: user
+//	    type: "*User"
+//	    out: internal/userlist_gen.go
+//	    package: internal
+//	    upstream: live
+//	    goversion: go1.21.5
+//	    typecheck: false
+//	    strict: true
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists the instantiations to generate.
+type Manifest struct {
+	// Package is the default package name of the generated files. When
+	// empty, it is the package found in the directory of each output.
+	Package string `json:"package" yaml:"package"`
+	// Generate lists the instantiations.
+	Generate []Instance `json:"generate" yaml:"generate"`
+
+	path string
+}
+
+// Instance is a single instantiation of a generator.
+type Instance struct {
+	Generator string `json:"generator" yaml:"generator"`
+	Name      string `json:"name" yaml:"name"`
+	Type      string `json:"type" yaml:"type"`
+	// Out is the output file, relative to the manifest's directory.
+	// It defaults to the lower cased name followed by "_gen.go".
+	// Instances sharing an output file are generated into a single file.
+	Out string `json:"out" yaml:"out"`
+	// Package overrides the manifest's package name.
+	Package string `json:"package" yaml:"package"`
+	// Version selects the upstream module version, if supported.
+	Version string `json:"version" yaml:"version"`
+	// Upstream, GoVersion, TypeCheck and Strict default to the -upstream,
+	// -goversion, -typecheck and -strict flags, which override them when
+	// they are given.
+	Upstream  string `json:"upstream" yaml:"upstream"`
+	GoVersion string `json:"goversion" yaml:"goversion"`
+	TypeCheck *bool  `json:"typecheck" yaml:"typecheck"`
+	Strict    *bool  `json:"strict" yaml:"strict"`
+
+	// Line is the line of the instance in the manifest.
+	Line int `json:"-" yaml:"-"`
+}
+
+// Load reads and validates the manifest at path.
+func Load(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{path: path}
+	var lines []int
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(m); err == nil {
+			lines, err = jsonLines(b)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err = dec.Decode(m); err == nil || err == io.EOF {
+			lines, err = yamlLines(b)
+		}
+	default:
+		return nil, fmt.Errorf("manifest %s: unsupported format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s: %s", path, err)
+	}
+	for i := range m.Generate {
+		if i < len(lines) {
+			m.Generate[i].Line = lines[i]
+		}
+		if err := m.validate(m.Generate[i]); err != nil {
+			return nil, fmt.Errorf("%s:%d: generate[%d]: %s", path, m.Generate[i].Line, i, err)
+		}
+	}
+	return m, nil
+}
+
+// yamlLines returns the lines of the entries of generate in the YAML
+// manifest b.
+func yamlLines(b []byte) ([]int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil || len(doc.Content) == 0 {
+		return nil, err
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "generate" {
+			continue
+		}
+		var lines []int
+		for _, n := range root.Content[i+1].Content {
+			lines = append(lines, n.Line)
+		}
+		return lines, nil
+	}
+	return nil, nil
+}
+
+// jsonLines returns the lines of the entries of generate in the JSON
+// manifest b, which is known to decode.
+func jsonLines(b []byte) ([]int, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil { // {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key != "generate" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+			return nil, err // null.
+		}
+		var lines []int
+		for dec.More() {
+			// the entry starts after the separator following the offset.
+			off := int(dec.InputOffset())
+			for off < len(b) && strings.IndexByte(" \t\r\n,", b[off]) >= 0 {
+				off++
+			}
+			lines = append(lines, 1+bytes.Count(b[:off], []byte("\n")))
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+		}
+		return lines, nil
+	}
+	return nil, nil
+}
+
+func (m *Manifest) validate(inst Instance) error {
+	switch {
+	case inst.Generator == "":
+		return fmt.Errorf("generator is required")
+	case inst.Name == "":
+		return fmt.Errorf("name is required")
+	case inst.Type == "":
+		return fmt.Errorf("type is required")
+	}
+	return nil
+}
+
+// Path returns the file the manifest was loaded from.
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// Dir returns the directory the manifest was loaded from.
+func (m *Manifest) Dir() string {
+	return filepath.Dir(m.path)
+}
+
+// PackageOf returns the package name of the file generated for inst.
+func (m *Manifest) PackageOf(inst Instance) string {
+	if inst.Package != "" {
+		return inst.Package
+	}
+	return m.Package
+}
+
+// OutOf returns the path of the file generated for inst.
+func (m *Manifest) OutOf(inst Instance) string {
+	out := inst.Out
+	if out == "" {
+		out = strings.ToLower(inst.Name) + "_gen.go"
+	}
+	if filepath.IsAbs(out) {
+		return out
+	}
+	return filepath.Join(m.Dir(), out)
+}
@@ -0,0 +1,82 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLines(t *testing.T) {
+	for _, c := range []struct {
+		name, src string
+		lines     [2]int
+	}{
+		{"m.yaml", `package: model
+generate:
+  - generator: sync/map
+    name: UserMap
+    type: map[string]*User
+
+  - name: user
+    generator: container/list
+    type: "*User"
+    strict: true
+`, [2]int{3, 7}},
+		{"m.json", `{
+  "package": "model",
+  "generate": [
+    {"generator": "sync/map", "name": "UserMap", "type": "map[string]*User"},
+
+    {
+      "name": "user", "generator": "container/list", "type": "*User", "strict": true
+    }
+  ]
+}
+`, [2]int{4, 6}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := Load(write(t, c.name, c.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Generate) != 2 {
+				t.Fatalf("got %d instances; want 2", len(m.Generate))
+			}
+			if got := [2]int{m.Generate[0].Line, m.Generate[1].Line}; got != c.lines {
+				t.Errorf("lines %v; want %v", got, c.lines)
+			}
+			if s := m.Generate[1].Strict; s == nil || !*s || m.Generate[0].Strict != nil {
+				t.Errorf("strict %v, %v; want nil, true", m.Generate[0].Strict, s)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{"m.yaml", "generate:\n  - generator: sync/map\n    name: M\n", "m.yaml:2: generate[0]: type is required"},
+		{"m.json", "{\"generate\": [\n{\"generator\": \"sync/map\", \"type\": \"map[int]int\"}]}", "m.json:2: generate[0]: name is required"},
+		{"m.yaml", "generate:\n  - generator: sync/map\n    nme: M\n", "field nme not found"},
+		{"m.json", `{"generate": [{"generator": "sync/map", "nme": "M"}]}`, `unknown field "nme"`},
+		{"m.toml", "", "unsupported format"},
+	} {
+		_, err := Load(write(t, c.name, c.src))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Load(%s %q) = %v; want an error containing %q", c.name, c.src, err, c.want)
+		}
+	}
+}
+
+// write writes src to the file name of a temporary directory and returns
+// its path.
+func write(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
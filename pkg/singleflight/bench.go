@@ -0,0 +1,64 @@
+package singleflight
+
+import "github.com/joesonw/go-generate/pkg/generator"
+
+// bench benchmarks Do on the group and on a singleflight.Group of
+// golang.org/x/sync, which must then be required by the module of the
+// generated file. The keys of singleflight.Group are the formatted keys.
+const bench = `import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// bench{{.Name}}Values returns n random keys and values.
+func bench{{.Name}}Values(t testing.TB, n int) ([]{{.Key}}, []{{.Value}}) {
+	rnd := rand.New(rand.NewSource(1))
+	key := {{template "value" .Key}}
+	value := {{template "value" .Value}}
+	keys, values := make([]{{.Key}}, n), make([]{{.Value}}, n)
+	for i := range keys {
+		keys[i], values[i] = key(), value()
+	}
+	return keys, values
+}
+
+func Benchmark{{.Name}}(b *testing.B) {
+	keys, values := bench{{.Name}}Values(b, 1024)
+	skeys := make([]string, len(keys))
+	for i, k := range keys {
+		skeys[i] = fmt.Sprint(k)
+	}
+
+	b.Run("Do/{{.Name}}", func(b *testing.B) {
+		var g {{.Name}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := values[i%len(values)]
+			g.Do(keys[i%len(keys)], func() ({{.Value}}, error) { return v, nil })
+		}
+	})
+	b.Run("Do/singleflight.Group", func(b *testing.B) {
+		var g singleflight.Group
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := values[i%len(values)]
+			g.Do(skeys[i%len(skeys)], func() (interface{}, error) { return v, nil })
+		}
+	})
+}
+`
+
+// Bench returns the benchmarks of the instantiation and of the
+// singleflight.Group of golang.org/x/sync.
+func (g *Generator) Bench() string {
+	return generator.RenderTest(bench, map[string]string{
+		"Name":  g.name,
+		"Key":   g.key,
+		"Value": g.value,
+	})
+}
@@ -2,21 +2,28 @@ package singleflight
 
 import (
 	"bytes"
-	"errors"
 	"go/ast"
-	"go/build"
-	"go/parser"
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	version "github.com/hashicorp/go-version"
 	"github.com/joesonw/go-generate/pkg/generator"
+	"github.com/joesonw/go-generate/pkg/snapshot"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
+func init() {
+	generator.Register(generator.Factory{
+		Name:        "singleflight",
+		Description: "typed copy of golang.org/x/sync/singleflight",
+		Args:        "map[K]V",
+		New:         New,
+	})
+}
+
 type versionSlice []*version.Version
 
 var _ sort.Interface = versionSlice([]*version.Version{})
@@ -33,20 +40,50 @@ func (s versionSlice) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
-func New(name, pkg, typ, ver string) (g *generator.Generator, err error) {
-	golangXPath := path.Join(build.Default.GOPATH, "pkg/mod/golang.org/x")
+// New returns the generator of the instantiation described by o. The
+// embedded snapshot is used unless o selects the live module cache or
+// another version.
+func New(o generator.Options) (g *generator.Generator, err error) {
+	defer generator.Catch(&err)
+	gen := &Generator{
+		name: o.Name,
+	}
+	var source generator.Source
+	if generator.UseSnapshot(o) && (o.Version == "" || o.Version == snapshot.Sync) {
+		source = generator.Snapshot("golang.org/x/sync", "singleflight/singleflight.go")
+	} else {
+		source = moduleSource(o.Version)
+	}
+	g, err = generator.New(o.Package, source, gen)
+
+	m, ok := generator.ParseType(o.Type).(*ast.MapType)
+	generator.ExpectKind(generator.BadArgument, ok, "invalid argument %q. expected map[T1]T2", o.Type)
+	b := bytes.NewBuffer(nil)
+	err = g.FormatNode(b, m.Key)
+	generator.Check(err, "format map key")
+	gen.key = b.String()
+	b.Reset()
+	err = g.FormatNode(b, m.Value)
+	generator.Check(err, "format map value")
+	gen.value = b.String()
+
+	gen.Generator = g
+	return g, err
+}
+
+// moduleSource returns singleflight.go of golang.org/x/sync at ver, or
+// at the latest version in the module cache if ver is empty.
+func moduleSource(ver string) generator.Source {
+	golangXPath := filepath.Join(generator.ModCache(), "golang.org", "x")
 	if _, err := os.Stat(golangXPath); os.IsNotExist(err) {
-		generator.Check(err, "please \"go get golang.org/x/sync/singleflight\" first")
+		generator.CheckKind(generator.SourceNotFound, err, "please \"go get golang.org/x/sync/singleflight\" first")
 	}
 
-	libraryPath := ""
-	if ver != "" {
-		libraryPath = path.Join(golangXPath, "sync@"+ver)
-	} else {
+	if ver == "" {
 
 		var syncVersions versionSlice
 		xFiles, err := ioutil.ReadDir(golangXPath)
-		generator.Check(err, "find versions of golang.org/x/sync/singleflight")
+		generator.CheckKind(generator.SourceNotFound, err, "find versions of golang.org/x/sync/singleflight")
 		for _, file := range xFiles {
 			name := file.Name()
 			if strings.HasPrefix(name, "sync@") {
@@ -55,34 +92,17 @@ func New(name, pkg, typ, ver string) (g *generator.Generator, err error) {
 				syncVersions = append(syncVersions, vv)
 			}
 		}
-		if len(syncVersions) == 0 {
-			panic(errors.New("please \"go get golang.org/x/sync/singleflight\" first"))
-		}
+		generator.ExpectKind(generator.SourceNotFound, len(syncVersions) > 0, "please \"go get golang.org/x/sync/singleflight\" first")
 		sort.Sort(syncVersions)
-		libraryPath = path.Join(golangXPath, "sync@v"+syncVersions[0].String())
+		ver = "v" + syncVersions[0].String()
 	}
-	println("using singleflight package from: " + libraryPath)
-
-	gen := &Generator{
-		name: name,
+	libraryPath := filepath.Join(golangXPath, "sync@"+ver)
+	return generator.Source{
+		Path:    filepath.Join(libraryPath, "singleflight", "singleflight.go"),
+		Module:  "golang.org/x/sync",
+		Version: ver,
+		File:    "singleflight/singleflight.go",
 	}
-	g, err = generator.New(pkg, libraryPath+"/singleflight/singleflight.go", gen)
-
-	exp, err := parser.ParseExpr(typ)
-	generator.Check(err, "parse expr: %s", typ)
-	m, ok := exp.(*ast.MapType)
-	generator.Expect(ok, "invalid argument. expected map[T1]T2")
-	b := bytes.NewBuffer(nil)
-	err = g.FormatNode(b, m.Key)
-	generator.Check(err, "format map key")
-	gen.key = b.String()
-	b.Reset()
-	err = g.FormatNode(b, m.Value)
-	generator.Check(err, "format map value")
-	gen.value = b.String()
-
-	gen.Generator = g
-	return g, err
 }
 
 type Generator struct {
@@ -117,6 +137,8 @@ func (g *Generator) Types() map[string]func(*ast.TypeSpec) {
 		"Result": func(n *ast.TypeSpec) {
 			generator.ReplaceIface(n, g.value)
 		},
+		// the recovered panic value stays any.
+		"panicError": func(n *ast.TypeSpec) {},
 	}
 }
 
@@ -140,6 +162,7 @@ func (g *Generator) Funcs() map[string]func(*ast.FuncDecl) {
 		"Forget": func(f *ast.FuncDecl) {
 			g.replaceKey(f.Type.Params.List[0])
 		},
+		"newPanicError": func(f *ast.FuncDecl) {},
 	}
 }
 
@@ -0,0 +1,70 @@
+package singleflight
+
+import (
+	"strings"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// test checks that concurrent calls of the same key share a single call
+// of the function, and that later calls run it again.
+const test = `import (
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+)
+
+func Test{{.Name}}(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	key := {{template "value" .Key}}
+	value := {{template "value" .Value}}
+
+	var g {{.Name}}
+	k, want := key(), value()
+	var calls int32
+	release := make(chan struct{})
+	fn := func() ({{.Value}}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return want, nil
+	}
+
+	// the second call joins the first one, which is blocked until release.
+	chans := []<-chan {{.Result}}{g.DoChan(k, fn), g.DoChan(k, fn)}
+	close(release)
+	for i, ch := range chans {
+		r := <-ch
+		if r.Err != nil || !reflect.DeepEqual(r.Val, want) || !r.Shared {
+			t.Errorf("call %d = %v, %v, shared %v; want %v, <nil>, shared true", i, r.Val, r.Err, r.Shared, want)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("function called %d times by concurrent calls; want 1", n)
+	}
+
+	v, err, shared := g.Do(k, fn)
+	if err != nil || !reflect.DeepEqual(v, want) || shared {
+		t.Errorf("Do() = %v, %v, %v; want %v, <nil>, false", v, err, shared, want)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("function called %d times after the calls returned; want 2", n)
+	}
+}
+`
+
+// Test returns the companion test of the instantiation.
+func (g *Generator) Test() string {
+	return generator.RenderTest(test, map[string]string{
+		"Name":   g.name,
+		"Result": "Result" + strings.Title(g.name),
+		"Key":    g.key,
+		"Value":  g.value,
+	})
+}
+
+// UpstreamTests returns the tests of singleflight, which use string keys.
+func (g *Generator) UpstreamTests() ([]string, string) {
+	return []string{"singleflight/singleflight_test.go"}, g.value
+}
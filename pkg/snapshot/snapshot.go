@@ -0,0 +1,39 @@
+// Package snapshot embeds pinned copies of the upstream sources mutated by
+// the generators, so that generated code does not depend on the Go
+// toolchain or module cache of whoever runs go-generate.
+//
+// Files are stored as _upstream/<module>@<version>/<file>, the layout of
+// the module cache, the standard library being the module "std". Each
+// module directory keeps the license of its files.
+package snapshot
+
+import (
+	"embed"
+	"path"
+)
+
+//go:embed _upstream
+var upstream embed.FS
+
+// Versions of the embedded modules.
+const (
+	Go   = "go1.23.12" // the standard library.
+	Sync = "v0.23.0"   // golang.org/x/sync.
+)
+
+// Version returns the embedded version of module.
+func Version(module string) (string, bool) {
+	switch module {
+	case "std":
+		return Go, true
+	case "golang.org/x/sync":
+		return Sync, true
+	}
+	return "", false
+}
+
+// ReadFile returns the embedded file of module at version, file being a
+// slash separated path within the module.
+func ReadFile(module, version, file string) ([]byte, error) {
+	return upstream.ReadFile(path.Join("_upstream", module+"@"+version, file))
+}
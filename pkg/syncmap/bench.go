@@ -0,0 +1,96 @@
+package syncmap
+
+import "github.com/joesonw/go-generate/pkg/generator"
+
+// bench benchmarks Store, Load and LoadOrStore on the map and on a
+// sync.Map, cycling through the same random keys and values.
+const bench = `import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/quick"
+)
+
+// bench{{.Name}}Values returns n random keys and values.
+func bench{{.Name}}Values(t testing.TB, n int) ([]{{.Key}}, []{{.Value}}) {
+	rnd := rand.New(rand.NewSource(1))
+	key := {{template "value" .Key}}
+	value := {{template "value" .Value}}
+	keys, values := make([]{{.Key}}, n), make([]{{.Value}}, n)
+	for i := range keys {
+		keys[i], values[i] = key(), value()
+	}
+	return keys, values
+}
+
+func Benchmark{{.Name}}(b *testing.B) {
+	keys, values := bench{{.Name}}Values(b, 1024)
+
+	b.Run("Store/{{.Name}}", func(b *testing.B) {
+		var m {{.Name}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			j := i % len(keys)
+			m.Store(keys[j], values[j])
+		}
+	})
+	b.Run("Store/sync.Map", func(b *testing.B) {
+		var m sync.Map
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			j := i % len(keys)
+			m.Store(keys[j], values[j])
+		}
+	})
+
+	b.Run("Load/{{.Name}}", func(b *testing.B) {
+		var m {{.Name}}
+		for i := range keys {
+			m.Store(keys[i], values[i])
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Load(keys[i%len(keys)])
+		}
+	})
+	b.Run("Load/sync.Map", func(b *testing.B) {
+		var m sync.Map
+		for i := range keys {
+			m.Store(keys[i], values[i])
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Load(keys[i%len(keys)])
+		}
+	})
+
+	b.Run("LoadOrStore/{{.Name}}", func(b *testing.B) {
+		var m {{.Name}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			j := i % len(keys)
+			m.LoadOrStore(keys[j], values[j])
+		}
+	})
+	b.Run("LoadOrStore/sync.Map", func(b *testing.B) {
+		var m sync.Map
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			j := i % len(keys)
+			m.LoadOrStore(keys[j], values[j])
+		}
+	})
+}
+`
+
+// Bench returns the benchmarks of the instantiation and of sync.Map.
+func (g *Generator) Bench() string {
+	return generator.RenderTest(bench, map[string]string{
+		"Name":  g.name,
+		"Key":   g.key,
+		"Value": g.value,
+	})
+}
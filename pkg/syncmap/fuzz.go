@@ -0,0 +1,120 @@
+package syncmap
+
+import "github.com/joesonw/go-generate/pkg/generator"
+
+// fuzz applies the operations decoded from the fuzz input to the map and
+// to a sync.Map, on a few random keys so that they collide. LoadAndDelete,
+// Swap, CompareAndSwap and CompareAndDelete are left out of the versions
+// not declaring them; the last two also of values which are not
+// comparable, as both maps panic comparing them. They compare with the
+// stored value half of the time, so that they succeed.
+const fuzz = `import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/quick"
+)
+
+func Fuzz{{.Name}}(f *testing.F) {
+	f.Add(int64(1), []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 19, 30, 41, 52, 61, 62, 64, 70, 71})
+	f.Fuzz(func(t *testing.T, seed int64, ops []byte) {
+		rnd := rand.New(rand.NewSource(seed))
+		key := {{template "value" .Key}}
+		value := {{template "value" .Value}}
+		keys := []{{.Key}}{key(), key(), key()}
+
+		var m {{.Name}}
+		var want sync.Map{{if or .CompareAndSwap .CompareAndDelete}}
+		valueComparable := reflect.TypeOf((*{{.Value}})(nil)).Elem().Comparable()
+		oldValue := func(k {{.Key}}) {{.Value}} {
+			if w, ok := want.Load(k); ok && rnd.Intn(2) == 0 {
+				return w.({{.Value}})
+			}
+			return value()
+		}{{end}}
+		same := func(op string, k {{.Key}}, v {{.Value}}, ok bool, w interface{}, wok bool) {
+			t.Helper()
+			if ok != wok || ok && !reflect.DeepEqual(v, w) {
+				t.Fatalf("%s(%v) = %v, %v; sync.Map returned %v, %v", op, k, v, ok, w, wok)
+			}
+		}
+		for _, op := range ops {
+			k := keys[int(op/9)%len(keys)]
+			switch op % 9 {
+			case 0:
+				v := value()
+				m.Store(k, v)
+				want.Store(k, v)
+			case 1:
+				v, ok := m.Load(k)
+				w, wok := want.Load(k)
+				same("Load", k, v, ok, w, wok)
+			case 2:
+				v := value()
+				actual, loaded := m.LoadOrStore(k, v)
+				wactual, wloaded := want.LoadOrStore(k, v)
+				same("LoadOrStore", k, actual, loaded, wactual, wloaded)
+				same("LoadOrStore", k, actual, true, wactual, true)
+			case 3:
+				m.Delete(k)
+				want.Delete(k){{if .LoadAndDelete}}
+			case 4:
+				v, ok := m.LoadAndDelete(k)
+				w, wok := want.LoadAndDelete(k)
+				same("LoadAndDelete", k, v, ok, w, wok){{end}}{{if .Swap}}
+			case 5:
+				v := value()
+				previous, loaded := m.Swap(k, v)
+				wprevious, wloaded := want.Swap(k, v)
+				same("Swap", k, previous, loaded, wprevious, wloaded){{end}}{{if .CompareAndSwap}}
+			case 6:
+				if !valueComparable {
+					continue
+				}
+				old, v := oldValue(k), value()
+				if swapped, wswapped := m.CompareAndSwap(k, old, v), want.CompareAndSwap(k, old, v); swapped != wswapped {
+					t.Fatalf("CompareAndSwap(%v, %v, %v) = %v; sync.Map returned %v", k, old, v, swapped, wswapped)
+				}{{end}}{{if .CompareAndDelete}}
+			case 7:
+				if !valueComparable {
+					continue
+				}
+				old := oldValue(k)
+				if deleted, wdeleted := m.CompareAndDelete(k, old), want.CompareAndDelete(k, old); deleted != wdeleted {
+					t.Fatalf("CompareAndDelete(%v, %v) = %v; sync.Map returned %v", k, old, deleted, wdeleted)
+				}{{end}}
+			default:
+				got := map[{{.Key}}]{{.Value}}{}
+				m.Range(func(k {{.Key}}, v {{.Value}}) bool {
+					got[k] = v
+					return true
+				})
+				n := 0
+				want.Range(func(k, w interface{}) bool {
+					n++
+					v, ok := got[k.({{.Key}})]
+					same("Range", k.({{.Key}}), v, ok, w, true)
+					return true
+				})
+				if n != len(got) {
+					t.Fatalf("Range visited %d entries; sync.Map visited %d", len(got), n)
+				}
+			}
+		}
+	})
+}
+`
+
+// Fuzz returns the fuzz test comparing the instantiation with sync.Map.
+func (g *Generator) Fuzz() string {
+	return generator.RenderTest(fuzz, map[string]interface{}{
+		"Name":             g.name,
+		"Key":              g.key,
+		"Value":            g.value,
+		"LoadAndDelete":    g.Declares(g.name + ".LoadAndDelete"),
+		"Swap":             g.Declares(g.name + ".Swap"),
+		"CompareAndSwap":   g.Declares(g.name + ".CompareAndSwap"),
+		"CompareAndDelete": g.Declares(g.name + ".CompareAndDelete"),
+	})
+}
@@ -5,22 +5,44 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
-	"runtime"
+	"go/token"
 	"strings"
 
 	"github.com/joesonw/go-generate/pkg/generator"
+	"github.com/joesonw/go-generate/pkg/snapshot"
 )
 
-func New(name, pkg, typ string) (g *generator.Generator, err error) {
+func init() {
+	generator.Register(generator.Factory{
+		Name:        "sync/map",
+		Description: "typed copy of sync.Map",
+		Args:        "map[K]V",
+		New:         New,
+	})
+}
+
+// New returns the generator of the instantiation described by o.
+func New(o generator.Options) (g *generator.Generator, err error) {
+	defer generator.Catch(&err)
 	gen := &Generator{
-		name: name,
+		name: o.Name,
+	}
+	source := generator.StdSource(o, "src/sync/map.go")
+	var note string
+	if !source.Embedded && !classic(source) {
+		// Go 1.24 and later wrap internal/sync.HashTrieMap.
+		generator.ExpectKind(generator.BadArgument, o.GoVersion == "",
+			"the sync.Map of %s is backed by a hash-trie map, which can not be generated; use -goversion %s or earlier", source.Version, snapshot.Go)
+		note = fmt.Sprintf("%s is backed by a hash-trie map, using the classic sync.Map of %s", source, snapshot.Go)
+		source = generator.Snapshot("std", "src/sync/map.go")
+	}
+	g, err = generator.New(o.Package, source, gen)
+	if note != "" {
+		g.Note("%s", note)
 	}
-	g, err = generator.New(pkg, fmt.Sprintf("%s/src/sync/map.go", runtime.GOROOT()), gen)
 
-	exp, err := parser.ParseExpr(typ)
-	generator.Check(err, "parse expr: %s", typ)
-	m, ok := exp.(*ast.MapType)
-	generator.Expect(ok, "invalid argument. expected map[T1]T2")
+	m, ok := generator.ParseType(o.Type).(*ast.MapType)
+	generator.ExpectKind(generator.BadArgument, ok, "invalid argument %q. expected map[T1]T2", o.Type)
 	b := bytes.NewBuffer(nil)
 	err = g.FormatNode(b, m.Key)
 	generator.Check(err, "format map key")
@@ -34,6 +56,29 @@ func New(name, pkg, typ string) (g *generator.Generator, err error) {
 	return g, err
 }
 
+// classic reports whether the sync.Map of src is the read-only and dirty
+// map implementation the handlers rewrite.
+func classic(src generator.Source) bool {
+	b, err := src.Read()
+	if err != nil {
+		return true // reported when the source is parsed.
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), src.Path, b, parser.SkipObjectResolution)
+	if err != nil {
+		return true
+	}
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+			for _, spec := range gd.Specs {
+				if spec.(*ast.TypeSpec).Name.Name == "readOnly" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 type Generator struct {
 	*generator.Generator
 	name  string
@@ -55,6 +100,7 @@ func (g *Generator) Types() map[string]func(*ast.TypeSpec) {
 			g.replaceKey(t.Type)
 		},
 		"readOnly": func(t *ast.TypeSpec) { g.replaceKey(t) },
+		"entry":    func(t *ast.TypeSpec) { g.replaceValue(t) },
 	}
 }
 
@@ -83,11 +129,47 @@ func (g *Generator) Funcs() map[string]func(*ast.FuncDecl) {
 		"Range": func(f *ast.FuncDecl) {
 			g.renameTuple(f.Type.Params.List[0].Type.(*ast.FuncType).Params)
 		},
+		"LoadAndDelete": func(f *ast.FuncDecl) {
+			g.replaceKey(f.Type.Params)
+			g.replaceValue(f.Type.Results)
+			generator.RenameNil(f.Body, f.Type.Results.List[0].Names[0].Name)
+		},
+		"delete": func(f *ast.FuncDecl) {
+			g.replaceValue(f)
+			generator.RenameNil(f.Body, f.Type.Results.List[0].Names[0].Name)
+		},
+		"Swap": func(f *ast.FuncDecl) {
+			g.renameTuple(f.Type.Params)
+			g.replaceValue(f.Type.Results)
+			generator.RenameNil(f.Body, f.Type.Results.List[0].Names[0].Name)
+		},
+		"CompareAndSwap": func(f *ast.FuncDecl) {
+			g.renameTuple(f.Type.Params)
+		},
+		"tryCompareAndSwap": func(f *ast.FuncDecl) {
+			g.replaceValue(f)
+			compareAsAny(f.Body)
+		},
+		"CompareAndDelete": func(f *ast.FuncDecl) {
+			g.renameTuple(f.Type.Params)
+			compareAsAny(f.Body)
+		},
 		"Delete":      func(f *ast.FuncDecl) { g.replaceKey(f) },
 		"newEntry":    func(f *ast.FuncDecl) { g.replaceValue(f) },
 		"tryStore":    func(f *ast.FuncDecl) { g.replaceValue(f) },
 		"dirtyLocked": func(f *ast.FuncDecl) { g.replaceKey(f) },
 		"storeLocked": func(f *ast.FuncDecl) { g.replaceValue(f) },
+		"swapLocked":  func(f *ast.FuncDecl) { g.replaceValue(f) },
+		"trySwap":     func(f *ast.FuncDecl) { g.replaceValue(f) },
+	}
+}
+
+// Optional returns the handlers of the functions Go 1.20 removed, in favor
+// of Swap, and of those it added.
+func (g *Generator) Optional() []string {
+	return []string{
+		"tryStore", "storeLocked",
+		"Swap", "swapLocked", "trySwap", "CompareAndSwap", "tryCompareAndSwap", "CompareAndDelete",
 	}
 }
 
@@ -95,13 +177,15 @@ func (g *Generator) replaceKey(n ast.Node) { generator.ReplaceIface(n, g.key) }
 
 func (g *Generator) replaceValue(n ast.Node) { generator.ReplaceIface(n, g.value) }
 
+// renameTuple types the first name of l as the key and the other names,
+// such as the value or the old and new values, as the value.
 func (g *Generator) renameTuple(l *ast.FieldList) {
 	if g.key == g.value {
 		g.replaceKey(l.List[0])
 		return
 	}
 	l.List = append(l.List, &ast.Field{
-		Names: []*ast.Ident{l.List[0].Names[1]},
+		Names: l.List[0].Names[1:],
 		Type:  l.List[0].Type,
 	})
 	l.List[0].Names = l.List[0].Names[:1]
@@ -109,7 +193,28 @@ func (g *Generator) renameTuple(l *ast.FieldList) {
 	g.replaceValue(l.List[1])
 }
 
+// compareAsAny turns the comparisons of stored values with old into
+// comparisons of interface values, so that the code compiles for values
+// of non comparable types. Like sync.Map, it panics if such values are
+// compared at run time.
+func compareAsAny(body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if b, ok := n.(*ast.BinaryExpr); ok && (b.Op == token.EQL || b.Op == token.NEQ) {
+			if id, ok := b.Y.(*ast.Ident); ok && id.Name == "old" {
+				b.X = &ast.CallExpr{Fun: ast.NewIdent("any"), Args: []ast.Expr{b.X}}
+				b.Y = &ast.CallExpr{Fun: ast.NewIdent("any"), Args: []ast.Expr{b.Y}}
+			}
+		}
+		return true
+	})
+}
+
 func (g *Generator) Mutate() error {
+	for _, h := range g.Missing() {
+		if h.Kind == "type" {
+			return generator.Errorf(generator.ShapeChanged, "type %s not found in %s", h.Name, g.Provenance().Source)
+		}
+	}
 	g.AddImport("sync")
 	g.Rename(map[string]string{
 		"Map":      g.name,
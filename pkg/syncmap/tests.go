@@ -0,0 +1,336 @@
+package syncmap
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// test exercises Store, Load, Range, LoadOrStore and Delete with random
+// keys and values.
+const test = `import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func Test{{.Name}}(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	key := {{template "value" .Key}}
+	value := {{template "value" .Value}}
+
+	var m {{.Name}}
+	want := map[{{.Key}}]{{.Value}}{}
+	for i := 0; i < 100 && len(want) < 10; i++ {
+		k, v := key(), value()
+		want[k] = v
+		m.Store(k, v)
+	}
+	for k, v := range want {
+		got, ok := m.Load(k)
+		if !ok || !reflect.DeepEqual(got, v) {
+			t.Errorf("Load(%v) = %v, %v; want %v, true", k, got, ok, v)
+		}
+		if got, loaded := m.LoadOrStore(k, value()); !loaded || !reflect.DeepEqual(got, v) {
+			t.Errorf("LoadOrStore(%v) = %v, %v; want %v, true", k, got, loaded, v)
+		}
+	}
+
+	n := 0
+	m.Range(func(k {{.Key}}, v {{.Value}}) bool {
+		n++
+		if w, ok := want[k]; !ok || !reflect.DeepEqual(v, w) {
+			t.Errorf("Range visited %v: %v; want %v", k, v, w)
+		}
+		return true
+	})
+	if n != len(want) {
+		t.Errorf("Range visited %d entries; want %d", n, len(want))
+	}
+
+	for k := range want {
+		m.Delete(k)
+		if got, ok := m.Load(k); ok {
+			t.Errorf("Load(%v) after Delete = %v, true; want false", k, got)
+		}
+	}
+}
+`
+
+// Test returns the companion test of the instantiation.
+func (g *Generator) Test() string {
+	return generator.RenderTest(test, map[string]string{
+		"Name":  g.name,
+		"Key":   g.key,
+		"Value": g.value,
+	})
+}
+
+// UpstreamTests returns the tests of sync.Map and the reference maps they
+// compare it with. Keys and values are both any upstream: the keys get the
+// key type in MutateUpstreamTest, the rest the value type.
+func (g *Generator) UpstreamTests() ([]string, string) {
+	return []string{"src/sync/map_test.go", "src/sync/map_reference_test.go"}, g.value
+}
+
+// keyed are the methods of maps taking a key as first parameter.
+var keyed = map[string]bool{
+	"Load": true, "Store": true, "LoadOrStore": true, "LoadAndDelete": true, "Delete": true,
+	"Swap": true, "CompareAndSwap": true, "CompareAndDelete": true,
+}
+
+// MutateUpstreamTest gives the key type to the empty interfaces of the
+// upstream tests holding keys: the keys of maps, the first parameter of
+// the methods of maps and of the functions they range over, and the
+// variables, fields and slices whose values are used as keys. The others
+// get the value type.
+func (g *Generator) MutateUpstreamTest(f *ast.File) []string {
+	if g.key == g.value {
+		return nil
+	}
+	uses := usedAsKeys(f)
+	first := func(p int, _ *ast.Ident) bool { return p == 0 }
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.MapType:
+			if isIface(n.Key) {
+				n.Key = generator.Expr(g.key, n.Key.Pos())
+			}
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				g.methodKeys(n.Name.Name, n.Type)
+			}
+		case *ast.Field:
+			// methods of interfaces, whose key may be unnamed.
+			if ft, ok := n.Type.(*ast.FuncType); ok && len(n.Names) == 1 {
+				g.methodKeys(n.Names[0].Name, ft)
+			}
+		case *ast.CallExpr:
+			// m.Range(func(k, v any) bool { ... })
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Range" && len(n.Args) == 1 {
+				if lit, ok := n.Args[0].(*ast.FuncLit); ok {
+					g.keyParams(lit.Type.Params, first)
+				}
+			}
+		case *ast.FuncType:
+			isKey := func(_ int, name *ast.Ident) bool { return name != nil && uses.vars[name.Obj] }
+			g.keyParams(n.Params, isKey)
+			g.keyParams(n.Results, isKey)
+		case *ast.StructType:
+			g.keyParams(n.Fields, func(_ int, name *ast.Ident) bool { return name != nil && uses.fields[name.Name] })
+		case *ast.ValueSpec:
+			// var keys []any
+			if a, ok := n.Type.(*ast.ArrayType); ok && len(n.Names) == 1 && uses.slices[n.Names[0].Obj] {
+				g.keyElem(a)
+			}
+		case *ast.AssignStmt:
+			// keys := make([]any, ...)
+			if n.Tok != token.DEFINE || len(n.Lhs) != len(n.Rhs) {
+				break
+			}
+			for i, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && uses.slices[id.Obj] {
+					if a := sliceType(n.Rhs[i]); a != nil {
+						g.keyElem(a)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return []string{g.key}
+}
+
+// methodKeys gives the key type to the first parameter of ft, the type of
+// the method name of a map, if the method takes a key. The first
+// parameter of the function given to Range gets it too.
+func (g *Generator) methodKeys(name string, ft *ast.FuncType) {
+	first := func(p int, _ *ast.Ident) bool { return p == 0 }
+	switch {
+	case keyed[name]:
+		g.keyParams(ft.Params, first)
+	case name == "Range" && ft.Params != nil && len(ft.Params.List) == 1:
+		if fn, ok := ft.Params.List[0].Type.(*ast.FuncType); ok {
+			g.keyParams(fn.Params, first)
+		}
+	}
+}
+
+// keyUses are the variables, fields and slices of the upstream tests
+// whose values are used as keys.
+type keyUses struct {
+	vars   map[*ast.Object]bool // variables and parameters holding keys.
+	slices map[*ast.Object]bool // variables holding slices of keys.
+	fields map[string]bool      // names of struct fields holding keys.
+}
+
+// usedAsKeys returns the variables, fields and slices of f whose values
+// are given as first argument to the methods of maps taking a key, index
+// maps, are deleted from maps, or are appended to or ranged over from
+// slices of such values.
+func usedAsKeys(f *ast.File) keyUses {
+	u := keyUses{vars: map[*ast.Object]bool{}, slices: map[*ast.Object]bool{}, fields: map[string]bool{}}
+	for changed := true; changed; {
+		changed = false
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				switch fun := n.Fun.(type) {
+				case *ast.SelectorExpr:
+					if keyed[fun.Sel.Name] && len(n.Args) > 0 {
+						changed = u.mark(n.Args[0]) || changed
+					}
+				case *ast.Ident:
+					// delete(m, k) and keys = append(keys, k).
+					if fun.Obj == nil && fun.Name == "delete" && len(n.Args) == 2 {
+						changed = u.mark(n.Args[1]) || changed
+					}
+					if fun.Obj == nil && fun.Name == "append" && len(n.Args) == 2 && u.isKey(n.Args[1]) {
+						changed = u.markSlice(n.Args[0]) || changed
+					}
+				}
+			case *ast.IndexExpr:
+				// only maps are indexed by empty interfaces.
+				changed = u.mark(n.Index) || changed
+			case *ast.RangeStmt:
+				// for _, k := range keys
+				if n.Value != nil && u.isKey(n.Value) {
+					changed = u.markSlice(n.X) || changed
+				}
+			}
+			return true
+		})
+	}
+	return u
+}
+
+// mark records that e holds keys, and reports whether it was not known.
+func (u keyUses) mark(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return u.mark(e.X)
+	case *ast.Ident:
+		if e.Obj != nil && e.Obj.Kind == ast.Var && !u.vars[e.Obj] {
+			u.vars[e.Obj] = true
+			return true
+		}
+	case *ast.SelectorExpr:
+		if !u.fields[e.Sel.Name] {
+			u.fields[e.Sel.Name] = true
+			return true
+		}
+	case *ast.IndexExpr:
+		return u.markSlice(e.X)
+	}
+	return false
+}
+
+// markSlice records that e holds a slice of keys, and reports whether it
+// was not known.
+func (u keyUses) markSlice(e ast.Expr) bool {
+	if id, ok := e.(*ast.Ident); ok && id.Obj != nil && id.Obj.Kind == ast.Var && !u.slices[id.Obj] {
+		u.slices[id.Obj] = true
+		return true
+	}
+	return false
+}
+
+// isKey reports whether e holds keys.
+func (u keyUses) isKey(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return u.isKey(e.X)
+	case *ast.Ident:
+		return u.vars[e.Obj]
+	case *ast.SelectorExpr:
+		return u.fields[e.Sel.Name]
+	case *ast.IndexExpr:
+		id, ok := e.X.(*ast.Ident)
+		return ok && u.slices[id.Obj]
+	}
+	return false
+}
+
+// sliceType returns the slice type of e, a call of make or a composite
+// literal, if any.
+func sliceType(e ast.Expr) *ast.ArrayType {
+	switch e := e.(type) {
+	case *ast.CallExpr:
+		if id, ok := e.Fun.(*ast.Ident); ok && id.Name == "make" && id.Obj == nil && len(e.Args) > 0 {
+			a, _ := e.Args[0].(*ast.ArrayType)
+			return a
+		}
+	case *ast.CompositeLit:
+		a, _ := e.Type.(*ast.ArrayType)
+		return a
+	}
+	return nil
+}
+
+// keyElem gives the key type to the elements of the slice type a, if they
+// are empty interfaces.
+func (g *Generator) keyElem(a *ast.ArrayType) {
+	if a.Len == nil && isIface(a.Elt) {
+		a.Elt = generator.Expr(g.key, a.Elt.Pos())
+	}
+}
+
+// keyParams gives the key type to the names of the fields of l for which
+// isKey is true, if their type is the empty interface. p counts the names
+// of l, an unnamed field counting as one; name is nil for unnamed fields.
+// Names sharing a field with names of another type get their own field.
+func (g *Generator) keyParams(l *ast.FieldList, isKey func(p int, name *ast.Ident) bool) {
+	if l == nil {
+		return
+	}
+	var list []*ast.Field
+	p := 0
+	for _, field := range l.List {
+		if !isIface(field.Type) {
+			p += max(len(field.Names), 1)
+			list = append(list, field)
+			continue
+		}
+		if len(field.Names) == 0 {
+			if isKey(p, nil) {
+				field.Type = generator.Expr(g.key, field.Type.Pos())
+			}
+			p++
+			list = append(list, field)
+			continue
+		}
+		// runs of names of the same type.
+		var runs []*ast.Field
+		var last bool
+		for _, name := range field.Names {
+			key := isKey(p, name)
+			p++
+			if len(runs) == 0 || key != last {
+				run := &ast.Field{Type: field.Type, Tag: field.Tag}
+				if key {
+					run.Type = generator.Expr(g.key, field.Type.Pos())
+				}
+				runs = append(runs, run)
+			}
+			runs[len(runs)-1].Names = append(runs[len(runs)-1].Names, name)
+			last = key
+		}
+		runs[0].Doc, runs[len(runs)-1].Comment = field.Doc, field.Comment
+		list = append(list, runs...)
+	}
+	l.List = list
+}
+
+// isIface reports whether e is the empty interface, spelled interface{} or
+// any.
+func isIface(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.InterfaceType:
+		return e.Methods == nil || len(e.Methods.List) == 0
+	case *ast.Ident:
+		return e.Name == "any" && e.Obj == nil
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/joesonw/go-generate/pkg/directive"
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// task is a job found in a go:generate directive.
+type task struct {
+	directive.Directive
+	job
+}
+
+// regen regenerates, in process and in parallel, every instantiation of
+// the go:generate directives found in the packages matching the patterns.
+func regen(args []string) error {
+	fs := newFlagSet("regen", flag.ExitOnError)
+	check := fs.Bool("check", false, "report out of date files instead of writing them")
+	force := fs.Bool("force", false, "regenerate files even if their recorded inputs are unchanged")
+	parallel := fs.Int("parallel", runtime.NumCPU(), "number of files generated concurrently")
+	watch := fs.Bool("watch", false, "keep running and regenerate when inputs change")
+	interval := fs.Duration("interval", time.Second, "polling interval of -watch")
+	fs.Parse(args)
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	if *watch {
+		w := &watcher{
+			interval: *interval,
+			load: func() ([]task, error) {
+				tasks, err := findTasks(patterns)
+				for i := range tasks {
+					tasks[i].Force = tasks[i].Force || *force
+				}
+				return tasks, err
+			},
+			definitions: func() ([]string, error) { return goFiles(patterns) },
+		}
+		return w.run()
+	}
+
+	start := time.Now()
+	tasks, err := findTasks(patterns)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		tasks[i].Force = tasks[i].Force || *force
+	}
+
+	cache := generator.NewCache()
+	diffs := make([]string, len(tasks))
+	written := make([]bool, len(tasks))
+	errs := make([]error, len(tasks))
+	forEach(len(tasks), *parallel, func(i int) {
+		t := tasks[i]
+		if *check {
+			diffs[i], errs[i] = t.check(cache)
+		} else {
+			written[i], errs[i] = t.run(cache)
+		}
+		if gerr, ok := errs[i].(*generator.Error); ok {
+			gerr.At(t.Pos)
+		}
+	})
+
+	failed, stale, skipped := 0, 0, 0
+	for i := range tasks {
+		if errs[i] != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, errs[i])
+		} else if !*check && !written[i] {
+			skipped++
+		}
+		if diffs[i] != "" {
+			stale++
+			fmt.Fprint(os.Stdout, diffs[i])
+		}
+	}
+	summarize(os.Stderr, summary{
+		total:   len(tasks),
+		failed:  failed,
+		stale:   stale,
+		skipped: skipped,
+		check:   *check,
+		elapsed: time.Since(start),
+	})
+	if failed > 0 || stale > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// findTasks returns the jobs of every go-generate directive.
+func findTasks(patterns []string) ([]task, error) {
+	directives, err := directive.Find(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []task
+	for _, d := range directives {
+		found, err := directiveTasks(d)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, found...)
+	}
+	return tasks, nil
+}
+
+// directiveTasks returns the jobs of a single go-generate directive.
+func directiveTasks(d directive.Directive) ([]task, error) {
+	args := d.Args
+	if len(args) > 0 && args[0] == "gen" {
+		args = args[1:]
+	}
+	f, err := parseFlags("gen", args, flag.ContinueOnError)
+	if err != nil {
+		return nil, err.(*generator.Error).At(d.Pos)
+	}
+	jobs, err := f.jobs(d.Dir, d.Package, d.Pos)
+	if err != nil {
+		if gerr, ok := err.(*generator.Error); ok {
+			gerr.At(d.Pos)
+		}
+		return nil, err
+	}
+	tasks := make([]task, 0, len(jobs))
+	for _, j := range jobs {
+		tasks = append(tasks, task{Directive: d, job: j})
+	}
+	return tasks, nil
+}
+
+// forEach calls fn for 0 <= i < n using up to parallel goroutines.
+func forEach(n, parallel int, fn func(i int)) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// summary counts the outcome of a regen run.
+type summary struct {
+	total, failed, stale, skipped int
+	check                         bool
+	elapsed                       time.Duration
+}
+
+func summarize(w io.Writer, s summary) {
+	verb := "regenerated"
+	if s.check {
+		verb = "checked"
+	}
+	fmt.Fprintf(w, "go-generate: %s %d file(s) in %s", verb, s.total-s.failed-s.skipped, s.elapsed.Round(time.Millisecond))
+	if s.skipped > 0 {
+		fmt.Fprintf(w, ", %d up to date", s.skipped)
+	}
+	if s.stale > 0 {
+		fmt.Fprintf(w, ", %d out of date", s.stale)
+	}
+	if s.failed > 0 {
+		fmt.Fprintf(w, ", %d failed", s.failed)
+	}
+	fmt.Fprintln(w)
+}
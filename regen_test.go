@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// TestRegen regenerates the directives of a package which already holds
+// its generated files, from the module root and from the package, as
+// regen does with relative patterns.
+func TestRegen(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"p/p.go": `package p
+
+import "bytes"
+
+var _ bytes.Buffer
+
+//go:generate go-generate -generator sync/map -name AMap map[string]*bytes.Buffer
+//go:generate go-generate -generator container/list -name buffer *bytes.Buffer
+`,
+		"p/q/q.go": `package q
+
+type T struct{}
+
+// no upstream test compiles with *T values: porting them warns.
+//go:generate go-generate -tests -upstreamtests -generator sync/map -name TMap map[string]*T
+`,
+	})
+	for _, c := range []struct{ dir, pattern string }{
+		{root, "./p"}, // generates the files.
+		{root, "./p"},
+		{filepath.Join(root, "p"), "."},
+		{root, "./..."},
+	} {
+		regenerate(t, c.dir, c.pattern)
+	}
+	for _, name := range []string{"p/amap_gen.go", "p/buffer_gen.go", "p/q/tmap_gen.go", "p/q/tmap_gen_upstream_test.go"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+	goCommand(t, root, "vet", "./...")
+	goCommand(t, root, "test", "-count=1", "./...")
+}
+
+// TestRegenImports checks that regen resolves qualified type arguments
+// with the imports of the file of the directive, when other files of the
+// package import another package of the same name.
+func TestRegenImports(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"p/p.go": `package p
+
+import "math/rand"
+
+var _ = rand.Int
+
+//go:generate go-generate -generator container/list -name source rand.Source
+`,
+		"p/crypto.go": `package p
+
+import "crypto/rand"
+
+var _ = rand.Reader
+`,
+	})
+	regenerate(t, root, "./p")
+	b, err := ioutil.ReadFile(filepath.Join(root, "p", "source_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"math/rand"`)) {
+		t.Errorf("source_gen.go does not import math/rand:\n%s", b)
+	}
+}
+
+// regenerate runs every task found by regen for pattern in dir, forcing
+// them to write their files.
+func regenerate(t *testing.T, dir, pattern string) {
+	t.Helper()
+	chdir(t, dir)
+	tasks, err := findTasks([]string{pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) == 0 {
+		t.Fatalf("no directive found for %s in %s", pattern, dir)
+	}
+	cache := generator.NewCache()
+	for _, task := range tasks {
+		task.Force = true
+		if _, err := task.run(cache); err != nil {
+			t.Fatalf("regen %s in %s: %v", pattern, dir, err)
+		}
+	}
+}
+
+// writeModule writes files, by slash-separated path, to a new module in a
+// temporary directory and returns the directory.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	files["go.mod"] = "module example.com/m\n\ngo 1.21\n"
+	for name, src := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// chdir changes the working directory to dir until the end of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
@@ -0,0 +1,20 @@
+// Package target is the package the golden files are generated for. Its
+// types are used as type arguments.
+package target
+
+import "bytes"
+
+// Key is a named key type.
+type Key string
+
+// User is a struct used by pointer and by value.
+type User struct {
+	ID   int
+	Name string
+}
+
+// Log imports bytes, so that the qualified type arguments are resolved
+// without listing the standard library.
+type Log struct {
+	bytes.Buffer
+}
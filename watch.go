@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joesonw/go-generate/pkg/directive"
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// watcher regenerates tasks whenever the files defining them or the
+// upstream sources they are generated from change.
+type watcher struct {
+	interval time.Duration
+	// load returns the tasks to keep up to date.
+	load func() ([]task, error)
+	// definitions returns the files whose change requires calling load
+	// again, such as go files holding directives or manifests.
+	definitions func() ([]string, error)
+
+	tasks   []task
+	sources map[string][]string // upstream files by task key.
+	mtimes  map[string]time.Time
+}
+
+// run generates every out of date task, then polls for changes until the
+// process is interrupted.
+func (w *watcher) run() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "go-generate: watching %d instantiation(s) for changes\n", len(w.tasks))
+	for range time.Tick(w.interval) {
+		w.poll()
+	}
+	return nil
+}
+
+// start loads the tasks, records the state of the watched files and
+// generates the tasks out of date.
+func (w *watcher) start() error {
+	tasks, err := w.load()
+	if err != nil {
+		return err
+	}
+	w.setTasks(tasks)
+	w.mtimes = map[string]time.Time{}
+	w.changed()
+	w.generate(tasks)
+	return nil
+}
+
+// poll regenerates the tasks affected by the files changed since the
+// previous call, reloading the tasks first.
+func (w *watcher) poll() {
+	changed := w.changed()
+	if len(changed) == 0 {
+		return
+	}
+	previous := map[string]bool{}
+	for _, t := range w.tasks {
+		previous[t.key()] = true
+	}
+	if tasks, err := w.load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		w.setTasks(tasks)
+	}
+
+	var affected []task
+	for _, t := range w.tasks {
+		if !previous[t.key()] || changed[t.Pos.Filename] || w.dependsOn(t, changed) {
+			affected = append(affected, t)
+		}
+	}
+	w.generate(affected)
+}
+
+// changed returns the watched files modified, created or removed since
+// the previous call.
+func (w *watcher) changed() map[string]bool {
+	files := map[string]bool{}
+	if w.definitions != nil {
+		defs, err := w.definitions()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		for _, f := range defs {
+			files[f] = true
+		}
+	}
+	for _, sources := range w.sources {
+		for _, s := range sources {
+			files[s] = true
+		}
+	}
+
+	changed := map[string]bool{}
+	for f := range files {
+		var mtime time.Time
+		if info, err := os.Stat(f); err == nil {
+			mtime = info.ModTime()
+		}
+		if old, ok := w.mtimes[f]; !ok || !old.Equal(mtime) {
+			changed[f] = true
+			w.mtimes[f] = mtime
+		}
+	}
+	for f := range w.mtimes {
+		if !files[f] {
+			changed[f] = true
+			delete(w.mtimes, f)
+		}
+	}
+	return changed
+}
+
+// generate runs the tasks with a fresh cache, since upstream sources may
+// have changed since the previous run.
+func (w *watcher) generate(tasks []task) {
+	cache := generator.NewCache()
+	for _, t := range tasks {
+		written, err := t.run(cache)
+		if gerr, ok := err.(*generator.Error); ok {
+			gerr.At(t.Pos)
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, err)
+		case written:
+			fmt.Fprintf(os.Stderr, "go-generate: wrote %s\n", t.Out)
+		}
+	}
+}
+
+// key identifies the instantiations and output of a task.
+func (t task) key() string {
+	return fmt.Sprintf("%s %+v", t.Out, t.Requests)
+}
+
+// setTasks replaces the watched tasks and resolves their upstream files.
+// Embedded snapshots never change and are not watched.
+func (w *watcher) setTasks(tasks []task) {
+	w.tasks = tasks
+	w.sources = map[string][]string{}
+	for _, t := range tasks {
+		var paths []string
+		for _, r := range t.Requests {
+			if s, err := r.Source(); err == nil && !s.Embedded {
+				paths = append(paths, s.Path)
+			}
+		}
+		w.sources[t.key()] = paths
+	}
+}
+
+func (w *watcher) dependsOn(t task, changed map[string]bool) bool {
+	for _, s := range w.sources[t.key()] {
+		if changed[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// goFiles returns the go files of the directories matched by patterns.
+func goFiles(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		dirs, err := directive.Match(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+	return files, nil
+}
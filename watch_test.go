@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joesonw/go-generate/pkg/generator"
+)
+
+// TestWatch checks that a directive run with -watch regenerates its file
+// when the directive is edited.
+func TestWatch(t *testing.T) {
+	const src = `package p
+
+import "bytes"
+
+var _ bytes.Buffer
+
+//go:generate go-generate -watch -generator container/list -name buffer *bytes.Buffer
+`
+	root := writeModule(t, map[string]string{"p/p.go": src})
+	chdir(t, filepath.Join(root, "p"))
+	f, err := parseFlags("go-generate", []string{"-watch", "-generator", "container/list", "-name", "buffer", "*bytes.Buffer"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := f.watcher(filepath.Join(root, "p"), "p", token.Position{Filename: "p.go", Line: 7})
+	if err := w.start(); err != nil {
+		t.Fatal(err)
+	}
+	generated := func() []byte {
+		b, err := ioutil.ReadFile("buffer_gen.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if b := generated(); !bytes.Contains(b, []byte("*bytes.Buffer")) {
+		t.Fatalf("buffer_gen.go is not a list of *bytes.Buffer:\n%s", b)
+	}
+
+	w.poll()
+	edited := bytes.Replace([]byte(src), []byte("*bytes.Buffer\n"), []byte("bytes.Buffer\n"), 1)
+	if err := ioutil.WriteFile("p.go", edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the modification time may not change within the resolution of the
+	// file system.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes("p.go", later, later); err != nil {
+		t.Fatal(err)
+	}
+	w.poll()
+	if b := generated(); bytes.Contains(b, []byte("*bytes.Buffer")) || !bytes.Contains(b, []byte("bytes.Buffer")) {
+		t.Errorf("buffer_gen.go was not regenerated for bytes.Buffer:\n%s", b)
+	}
+}
+
+// TestWatchLive checks that the upstream files of live requests are
+// watched, and those of the embedded snapshots are not.
+func TestWatchLive(t *testing.T) {
+	for _, c := range []struct {
+		upstream string
+		want     []string
+	}{
+		{generator.Embedded, nil},
+		{generator.Live, []string{generator.GoSource("src/container/list/list.go").Path}},
+	} {
+		f, err := parseFlags("go-generate", []string{"-upstream", c.upstream, "-generator", "container/list", "-name", "ints", "int"}, flag.ContinueOnError)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := f.watcher(t.TempDir(), "p", token.Position{})
+		tasks, err := w.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.setTasks(tasks)
+		if got := w.sources[tasks[0].key()]; len(got) != len(c.want) || len(got) > 0 && got[0] != c.want[0] {
+			t.Errorf("-upstream %s: watched %q, want %q", c.upstream, got, c.want)
+		}
+	}
+}